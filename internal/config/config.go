@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Tarow/dockdns/internal/constants"
@@ -23,23 +24,39 @@ func (c *AppConfig) EnrichZoneSecretsFromEnv() {
 	sanitizeRegexp := regexp.MustCompile(`[^a-zA-Z0-9]`)
 
 	for i, zone := range c.Zones {
-		envZoneName := strings.ToUpper(sanitizeRegexp.ReplaceAllString(zone.Name, "_"))
-
-		if zone.ApiToken == "" {
-			e := envZoneName + "_API_TOKEN"
-			if val, ok := os.LookupEnv(e); ok {
-				c.Zones[i].ApiToken = val
-			}
+		// Entries without name use the provider as prefix, e.g. CLOUDFLARE_API_TOKEN
+		name := zone.Name
+		if name == "" {
+			name = zone.Provider
 		}
-		if zone.ZoneID == "" {
-			e := envZoneName + "_ZONE_ID"
-			if val, ok := os.LookupEnv(e); ok {
-				c.Zones[i].ZoneID = val
-			}
+		envZoneName := strings.ToUpper(sanitizeRegexp.ReplaceAllString(name, "_"))
+
+		c.Zones[i].setSecretsFromEnv(envZoneName)
+		// Backends share the zone name, so their variables are numbered, e.g. EXAMPLE_COM_BACKEND_1_API_TOKEN
+		for j := range zone.Backends {
+			c.Zones[i].Backends[j].setSecretsFromEnv(envZoneName + "_BACKEND_" + strconv.Itoa(j+1))
 		}
 	}
 }
 
+func (z *Zone) setSecretsFromEnv(prefix string) {
+	setFromEnv(&z.ApiToken, prefix+"_API_TOKEN")
+	setFromEnv(&z.ZoneID, prefix+"_ZONE_ID")
+	setFromEnv(&z.TSIGSecret, prefix+"_TSIG_SECRET")
+	setFromEnv(&z.Password, prefix+"_PASSWORD")
+	setFromEnv(&z.ClientSecret, prefix+"_CLIENT_SECRET")
+}
+
+// setFromEnv sets target to the value of the environment variable, unless target already has a value
+func setFromEnv(target *string, env string) {
+	if *target != "" {
+		return
+	}
+	if val, ok := os.LookupEnv(env); ok {
+		*target = val
+	}
+}
+
 type LogFormat string
 
 const LogFormatSimple = "simple"
@@ -56,6 +73,66 @@ type Zone struct {
 	Name     string `yaml:"name"`
 	ApiToken string `yaml:"apiToken"`
 	ZoneID   string `yaml:"zoneID"`
+	ApiURL   string `yaml:"apiURL"`
+	ServerID string `yaml:"serverID"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	ClientIP string `yaml:"clientIP"`
+	Command  string `yaml:"command"`
+
+	// Addresses used instead of the public IP for domains without explicit address, e.g. for a local resolver
+	LanIP4 string `yaml:"lanIP4"`
+	LanIP6 string `yaml:"lanIP6"`
+
+	// Cloudflare account owning the tunnels, only needed for tunneled domains
+	AccountID string `yaml:"accountID"`
+
+	// Google Cloud DNS
+	Project         string `yaml:"project"`
+	CredentialsFile string `yaml:"credentialsFile"`
+
+	// Azure DNS
+	TenantID       string `yaml:"tenantID"`
+	ClientID       string `yaml:"clientID"`
+	ClientSecret   string `yaml:"clientSecret"`
+	SubscriptionID string `yaml:"subscriptionID"`
+	ResourceGroup  string `yaml:"resourceGroup"`
+	AuthURL        string `yaml:"authURL"`
+
+	// Passed to provider plugins as is
+	Options map[string]string `yaml:"options"`
+
+	// Record fields the backend of an exec or http provider stores
+	Capabilities *Capabilities `yaml:"capabilities"`
+
+	// File based providers
+	Path          string `yaml:"path"`
+	ReloadCommand string `yaml:"reloadCommand"`
+	Format        string `yaml:"format"`
+	ReloadProcess string `yaml:"reloadProcess"`
+
+	// RFC 2136 (dynamic DNS update)
+	Nameserver    string `yaml:"nameserver"`
+	TSIGKeyName   string `yaml:"tsigKeyName"`
+	TSIGSecret    string `yaml:"tsigSecret"`
+	TSIGAlgorithm string `yaml:"tsigAlgorithm"`
+
+	// etcd (CoreDNS SkyDNS layout)
+	Endpoints []string `yaml:"endpoints"`
+	Prefix    string   `yaml:"prefix"`
+
+	// Further providers the zone is mirrored to, e.g. a local resolver next to the public DNS.
+	// Backends take the name of the zone, everything else is configured per backend.
+	Backends []Zone `yaml:"backends"`
+}
+
+type Capabilities struct {
+	// Supported record types, all types are supported if empty
+	RecordTypes []string `yaml:"recordTypes"`
+	// Unset fields keep the default of the provider
+	Proxied *bool `yaml:"proxied"`
+	Comment *bool `yaml:"comment"`
+	TTL     *bool `yaml:"ttl"`
 }
 
 type DNS struct {
@@ -71,9 +148,15 @@ type DomainRecord struct {
 	IP4     string `yaml:"a" label:"dockdns.a"`
 	IP6     string `yaml:"aaaa" label:"dockdns.aaaa"`
 	CName   string `yaml:"cname" label:"dockdns.cname"`
+	TXT     string `yaml:"txt" label:"dockdns.txt"`
+	MX      string `yaml:"mx" label:"dockdns.mx"`
 	TTL     int    `yaml:"ttl" label:"dockdns.ttl"`
 	Proxied bool   `yaml:"proxied" label:"dockdns.proxied"`
 	Comment string `yaml:"comment" label:"dockdns.comment"`
+	// Name of the Cloudflare Tunnel the domain is exposed through, replaces the A, AAAA and CNAME settings
+	Tunnel string `yaml:"tunnel" label:"dockdns.tunnel"`
+	// Service URL the tunnel forwards to, e.g. http://app:8080. Defaults to the container name and port
+	Service string `yaml:"service" label:"dockdns.tunnel.service"`
 }
 
 func (d DomainRecord) GetContent(recordType string) string {
@@ -84,6 +167,8 @@ func (d DomainRecord) GetContent(recordType string) string {
 		return d.IP6
 	case constants.RecordTypeCNAME:
 		return d.CName
+	case constants.RecordTypeTXT:
+		return d.TXT
 	default:
 		return ""
 	}
@@ -3,5 +3,7 @@ package constants
 const RecordTypeA = "A"
 const RecordTypeAAAA = "AAAA"
 const RecordTypeCNAME = "CNAME"
+const RecordTypeTXT = "TXT"
+const RecordTypeMX = "MX"
 
 const DockdnsNameLabel = "dockdns.name"
@@ -0,0 +1,64 @@
+package dns
+
+import "log/slog"
+
+// applyChanges sends all changes to the provider. Batch capable providers receive them in a single call,
+// all other providers get one call per change.
+func applyChanges(provider Provider, changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	if batchProvider, ok := provider.(BatchProvider); ok {
+		if err := batchProvider.Apply(changes); err != nil {
+			slog.Error("failed to apply changes", "changes", len(changes), "error", err)
+			return
+		}
+		for _, change := range changes {
+			logChange(change.Action, change.Record)
+		}
+		return
+	}
+
+	for _, change := range changes {
+		applyChange(provider, change)
+	}
+}
+
+func applyChange(provider Provider, change Change) {
+	var err error
+	record := change.Record
+
+	switch change.Action {
+	case ActionCreate:
+		record, err = provider.Create(change.Record)
+		if err != nil {
+			slog.Error("failed to create record", "record", change.Record, "error", err)
+			return
+		}
+	case ActionUpdate:
+		record, err = provider.Update(change.Record)
+		if err != nil {
+			slog.Error("failed to update record", "record", change.Record, "error", err)
+			return
+		}
+	case ActionDelete:
+		if err = provider.Delete(change.Record); err != nil {
+			slog.Error("failed to purge record", "name", record.Name, "type", record.Type, "error", err)
+			return
+		}
+	}
+
+	logChange(change.Action, record)
+}
+
+func logChange(action ChangeAction, record Record) {
+	switch action {
+	case ActionCreate:
+		slog.Info("Successfully created new record", "name", record.Name, "content", record.Content, "type", record.Type, "ttl", record.TTL, "proxied", record.Proxied, "comment", record.Comment)
+	case ActionUpdate:
+		slog.Info("Successfully updated record", "name", record.Name, "content", record.Content, "type", record.Type, "ttl", record.TTL, "proxied", record.Proxied, "comment", record.Comment)
+	case ActionDelete:
+		slog.Info("successfully purged unknown record", "name", record.Name, "type", record.Type)
+	}
+}
@@ -0,0 +1,81 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Capabilities describe which record types and fields a provider supports
+type Capabilities struct {
+	// Supported record types, an empty list means all types are supported
+	RecordTypes []string
+	Proxied     bool
+	Comment     bool
+	TTL         bool
+	// Lowest TTL accepted by the provider, 0 if there is no limit
+	MinTTL int
+}
+
+// CapabilityProvider is implemented by providers that only support a subset of the record fields.
+// Providers not implementing it are expected to support all of them.
+type CapabilityProvider interface {
+	Capabilities() Capabilities
+}
+
+var AllCapabilities = Capabilities{
+	Proxied: true,
+	Comment: true,
+	TTL:     true,
+}
+
+func CapabilitiesOf(provider Provider) Capabilities {
+	if cp, ok := provider.(CapabilityProvider); ok {
+		return cp.Capabilities()
+	}
+	return AllCapabilities
+}
+
+// Supports reports whether records of the type can be managed by the provider
+func (c Capabilities) Supports(recordType string) bool {
+	return len(c.RecordTypes) == 0 || slices.Contains(c.RecordTypes, recordType)
+}
+
+// Validate returns an error if the record uses a type or field the provider does not support.
+// A TTL of 0 is treated as 'provider default'.
+func (c Capabilities) Validate(record Record) error {
+	if !c.Supports(record.Type) {
+		return fmt.Errorf("record type %s is not supported, supported types: %v", record.Type, c.RecordTypes)
+	}
+	if record.Proxied && !c.Proxied {
+		return errors.New("proxied records are not supported")
+	}
+	if record.Comment != "" && !c.Comment {
+		return errors.New("record comments are not supported")
+	}
+	if record.TTL != 0 && !c.TTL {
+		return errors.New("custom TTLs are not supported")
+	}
+	if record.TTL != 0 && record.TTL < c.MinTTL {
+		return fmt.Errorf("TTL %d is below the minimum TTL %d of the provider", record.TTL, c.MinTTL)
+	}
+	return nil
+}
+
+// Strip clears the fields of the record the provider does not support and raises TTLs below the minimum.
+// The record type is left untouched
+func (c Capabilities) Strip(record Record) Record {
+	if !c.Proxied {
+		record.Proxied = false
+	}
+	if !c.Comment {
+		record.Comment = ""
+	}
+	if !c.TTL {
+		record.TTL = 0
+	}
+	if record.TTL != 0 && record.TTL < c.MinTTL {
+		record.TTL = c.MinTTL
+	}
+	return record
+}
@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+// minTTLProvider only accepts TTLs of at least an hour, like deSEC
+type minTTLProvider struct {
+	fakeProvider
+}
+
+func (p minTTLProvider) Capabilities() Capabilities {
+	return Capabilities{TTL: true, MinTTL: 3600}
+}
+
+func TestPlanMinTTL(t *testing.T) {
+	h := Handler{DnsCfg: config.DNS{EnableIP4: true, DefaultTTL: 300}}
+	backend := Backend{Provider: minTTLProvider{}}
+
+	// The default TTL is raised to the minimum
+	changes := h.planUpdates(backend, []config.DomainRecord{{Name: "example.com", IP4: "10.0.0.1"}}, nil)
+	if len(changes) != 1 || changes[0].Record.TTL != 3600 {
+		t.Errorf("planUpdates() = %+v, want a record with TTL 3600", changes)
+	}
+
+	// Explicit TTLs below the minimum are rejected
+	changes = h.planUpdates(backend, []config.DomainRecord{{Name: "example.com", IP4: "10.0.0.1", TTL: 60}}, nil)
+	if len(changes) != 0 {
+		t.Errorf("planUpdates() = %+v, want the record to be skipped", changes)
+	}
+
+	// Mirrors raise them instead
+	backend.Mirror = true
+	changes = h.planUpdates(backend, []config.DomainRecord{{Name: "example.com", IP4: "10.0.0.1", TTL: 60}}, nil)
+	if len(changes) != 1 || changes[0].Record.TTL != 3600 {
+		t.Errorf("planUpdates() = %+v, want a record with TTL 3600", changes)
+	}
+}
+
+func TestCapabilitiesSupports(t *testing.T) {
+	if !(Capabilities{}).Supports(constants.RecordTypeMX) {
+		t.Error("capabilities without record types should support all types")
+	}
+	caps := Capabilities{RecordTypes: []string{constants.RecordTypeA}}
+	if !caps.Supports(constants.RecordTypeA) || caps.Supports(constants.RecordTypeAAAA) {
+		t.Errorf("Supports() does not follow the record types %v", caps.RecordTypes)
+	}
+}
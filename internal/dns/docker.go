@@ -38,6 +38,10 @@ func parseContainerLabels(containers []container.Summary) ([]config.DomainRecord
 			continue
 		}
 
+		if record.Tunnel != "" && record.Service == "" {
+			record.Service = defaultService(container)
+		}
+
 		// Name label can have multiple comma separated domains. Create a record for all of them
 		domains := strings.Split(record.Name, ",")
 		for _, domain := range domains {
@@ -51,6 +55,25 @@ func parseContainerLabels(containers []container.Summary) ([]config.DomainRecord
 	return labelRecords, nil
 }
 
+// defaultService returns the URL of the container on the docker network, using its lowest exposed TCP port
+func defaultService(container container.Summary) string {
+	if len(container.Names) == 0 {
+		return ""
+	}
+	service := "http://" + strings.TrimPrefix(container.Names[0], "/")
+
+	var port uint16
+	for _, p := range container.Ports {
+		if p.Type == "tcp" && (port == 0 || p.PrivatePort < port) {
+			port = p.PrivatePort
+		}
+	}
+	if port != 0 {
+		service += ":" + strconv.Itoa(int(port))
+	}
+	return service
+}
+
 func parseLabels(container container.Summary, targetStruct *config.DomainRecord) error {
 	containerLabels := container.Labels
 	targetValue := reflect.ValueOf(targetStruct)
@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+// domainMX parses the MX setting of a domain, a comma separated list of '<priority> <target>' pairs
+func domainMX(domain config.DomainRecord) ([]Record, error) {
+	var records []Record
+	for _, value := range strings.Split(domain.MX, ",") {
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid mx value '%s', expected '<priority> <target>'", strings.TrimSpace(value))
+		}
+		priority, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mx priority '%s': %w", fields[0], err)
+		}
+
+		records = append(records, Record{
+			Name:     domain.Name,
+			Content:  strings.TrimSuffix(fields[1], "."),
+			Type:     constants.RecordTypeMX,
+			Priority: int(priority),
+			TTL:      domain.TTL,
+			Comment:  domain.Comment,
+		})
+	}
+	return records, nil
+}
+
+// FormatMX returns the presentation format of an MX record, e.g. '10 mail.example.com.'
+func FormatMX(record Record) string {
+	return fmt.Sprintf("%d %s.", record.Priority, strings.TrimSuffix(record.Content, "."))
+}
+
+// ParseMX splits an MX value in presentation format into its priority and target.
+// Malformed values are returned as target with priority 0.
+func ParseMX(value string) (int, string) {
+	priority, target, found := strings.Cut(strings.TrimSpace(value), " ")
+	p, err := strconv.ParseUint(priority, 10, 16)
+	if !found || err != nil {
+		return 0, strings.TrimSuffix(value, ".")
+	}
+	return int(p), strings.TrimSuffix(strings.TrimSpace(target), ".")
+}
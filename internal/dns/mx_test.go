@@ -0,0 +1,62 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+func TestParseMX(t *testing.T) {
+	tests := []struct {
+		value    string
+		priority int
+		target   string
+	}{
+		{"10 mx.example.com.", 10, "mx.example.com"},
+		{" 20  mx.example.com ", 20, "mx.example.com"},
+		{"0 .", 0, ""},
+		{"mx.example.com.", 0, "mx.example.com"},
+		{"70000 mx.example.com", 0, "70000 mx.example.com"},
+	}
+	for _, tt := range tests {
+		priority, target := ParseMX(tt.value)
+		if priority != tt.priority || target != tt.target {
+			t.Errorf("ParseMX(%q) = %d, %q, want %d, %q", tt.value, priority, target, tt.priority, tt.target)
+		}
+	}
+}
+
+func TestFormatMX(t *testing.T) {
+	for _, content := range []string{"mx.example.com", "mx.example.com."} {
+		record := Record{Type: constants.RecordTypeMX, Content: content, Priority: 10}
+		if got := FormatMX(record); got != "10 mx.example.com." {
+			t.Errorf("FormatMX(%+v) = %s, want 10 mx.example.com.", record, got)
+		}
+		if priority, target := ParseMX(FormatMX(record)); priority != 10 || target != "mx.example.com" {
+			t.Errorf("ParseMX(FormatMX(%+v)) = %d, %s", record, priority, target)
+		}
+	}
+}
+
+func TestDomainMX(t *testing.T) {
+	domain := config.DomainRecord{Name: "example.com", MX: "10 mx1.example.com., 20 mx2.example.com", TTL: 300}
+	got, err := domainMX(domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx1.example.com", Priority: 10, TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx2.example.com", Priority: 20, TTL: 300},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("domainMX() = %+v, want %+v", got, want)
+	}
+
+	for _, mx := range []string{"mx.example.com", "high mx.example.com", "10 mx1.example.com,", "70000 mx.example.com"} {
+		if _, err := domainMX(config.DomainRecord{Name: "example.com", MX: mx}); err == nil {
+			t.Errorf("domainMX(%q) accepted an invalid value", mx)
+		}
+	}
+}
@@ -8,22 +8,27 @@ import (
 	"github.com/Tarow/dockdns/internal/constants"
 )
 
-func (h Handler) purgeUnknownRecords(provider Provider, domains []config.DomainRecord) {
+// planPurge returns the deletions of all existing records that don't belong to any of the domains.
+// TXT and MX values are only deleted if dockdns set them in the previous run, other ones are often maintained by hand.
+func (h Handler) planPurge(provider Provider, domains, previous []config.DomainRecord) []Change {
 	existingRecords, err := provider.List()
 	if err != nil {
 		slog.Error("failed to fetch existing records, skipping purge", "error", err)
-		return
+		return nil
 	}
 
+	var changes []Change
 	for _, record := range existingRecords {
-		if !containsRecord(domains, record, h.DnsCfg) {
-			if err := provider.Delete(record); err != nil {
-				slog.Error("failed to purge record", "name", record.Name, "type", record.Type, "error", err)
-			} else {
-				slog.Info("successfully purged unknown record", "name", record.Name, "type", record.Type)
-			}
+		if containsRecord(domains, record, h.DnsCfg) {
+			continue
 		}
+		if isValueType(record.Type) && !ownedValue(previous, record) {
+			continue
+		}
+		changes = append(changes, Change{Action: ActionDelete, Record: record})
 	}
+
+	return changes
 }
 
 // Check if an entry with same domain and type exists
@@ -42,6 +47,14 @@ func containsRecord(domains []config.DomainRecord, toCheck Record, dnsCfg config
 				if dnsCfg.EnableIP6 && toCheck.Type == constants.RecordTypeAAAA {
 					return true
 				}
+				// Stale TXT values of a name with TXT configuration are removed when planning the TXT records
+				if domain.TXT != "" && toCheck.Type == constants.RecordTypeTXT {
+					return true
+				}
+				// Stale MX records of a name with MX configuration are removed when planning the MX records
+				if strings.TrimSpace(domain.MX) != "" && toCheck.Type == constants.RecordTypeMX {
+					return true
+				}
 			}
 		}
 	}
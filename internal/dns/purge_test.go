@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+func TestPlanPurgeTXT(t *testing.T) {
+	spf := txt("example.com", "v=spf1 mx -all")
+	token := txt("_acme-challenge.example.com", "token")
+	stale := aRecord("stale.example.com", "1.2.3.4")
+
+	h := Handler{DnsCfg: config.DNS{EnableIP4: true, DefaultTTL: 300}}
+	provider := fakeProvider{records: []Record{spf, token, stale}}
+	previous := []config.DomainRecord{{Name: "_acme-challenge.example.com", TXT: "token"}}
+
+	got := h.planPurge(provider, nil, previous)
+	want := []Change{{Action: ActionDelete, Record: token}, {Action: ActionDelete, Record: stale}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planPurge() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPlanPurgeMX(t *testing.T) {
+	handMade := mx("example.com", 10, "mail.provider.com")
+	owned := mx("app.example.com", 10, "mx.example.com")
+
+	h := Handler{DnsCfg: config.DNS{EnableIP4: true, DefaultTTL: 300}}
+	provider := fakeProvider{records: []Record{handMade, owned}}
+	previous := []config.DomainRecord{{Name: "app.example.com", MX: "10 mx.example.com"}}
+
+	got := h.planPurge(provider, nil, previous)
+	want := []Change{{Action: ActionDelete, Record: owned}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planPurge() = %+v, want %+v", got, want)
+	}
+}
+
+func TestContainsRecord(t *testing.T) {
+	domains := []config.DomainRecord{
+		{Name: "example.com", IP4: "10.0.0.1"},
+		{Name: "alias.example.com", CName: "example.com", TXT: "ignored"},
+		{Name: "mail.example.com", TXT: "v=spf1 -all", MX: "10 mx.example.com"},
+	}
+	dnsCfg := config.DNS{EnableIP4: true}
+
+	tests := []struct {
+		record Record
+		want   bool
+	}{
+		{aRecord("example.com", "10.0.0.2"), true},
+		{Record{Name: "example.com", Type: constants.RecordTypeAAAA, Content: "fd00::1"}, false},
+		{Record{Name: "example.com", Type: constants.RecordTypeCNAME, Content: "other.com"}, false},
+		{txt("example.com", "v=spf1 -all"), false},
+		{Record{Name: "alias.example.com", Type: constants.RecordTypeCNAME, Content: "example.com"}, true},
+		{aRecord("alias.example.com", "10.0.0.1"), false},
+		{txt("alias.example.com", "ignored"), false},
+		{txt("mail.example.com", "stale"), true},
+		{mx("mail.example.com", 20, "old.example.com"), true},
+		{aRecord("unknown.example.com", "10.0.0.1"), false},
+	}
+	for _, tt := range tests {
+		if got := containsRecord(domains, tt.record, dnsCfg); got != tt.want {
+			t.Errorf("containsRecord(%s %s) = %v, want %v", tt.record.Type, tt.record.Name, got, tt.want)
+		}
+	}
+
+	if !containsRecord(domains, Record{Name: "example.com", Type: constants.RecordTypeAAAA}, config.DNS{EnableIP6: true}) {
+		t.Error("containsRecord() = false for an AAAA record with IPv6 enabled")
+	}
+}
@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+// RRSet holds all values of a name and type in presentation format. Providers like Route 53 or Cloud DNS
+// only manage whole record sets, so the changes of a run are merged into the sets they belong to.
+type RRSet struct {
+	Name   string
+	Type   string
+	TTL    int
+	Values []string
+}
+
+// RRSetChange replaces the existing record set with the desired one. Existing is nil for new sets,
+// a desired set without values deletes the existing one.
+type RRSetChange struct {
+	Existing *RRSet
+	Desired  RRSet
+}
+
+// MergeRRSets merges the changes into the record sets they belong to, in the order the sets first appear.
+// fetch returns the existing set of a name and type or nil, value returns the presentation format of a record.
+// Sets the changes leave as they are are omitted.
+func MergeRRSets(changes []Change, fetch func(name, recordType string) (*RRSet, error), value func(Record) string) ([]RRSetChange, error) {
+	var keys []string
+	sets := map[string]*RRSetChange{}
+
+	for _, change := range changes {
+		record := change.Record
+		key := strings.ToLower(record.Name) + "/" + record.Type
+
+		if _, seen := sets[key]; !seen {
+			existing, err := fetch(record.Name, record.Type)
+			if err != nil {
+				return nil, err
+			}
+
+			set := &RRSetChange{Existing: existing, Desired: RRSet{Name: record.Name, Type: record.Type}}
+			if existing != nil {
+				set.Desired.TTL = existing.TTL
+				set.Desired.Values = slices.Clone(existing.Values)
+			}
+			keys = append(keys, key)
+			sets[key] = set
+		}
+
+		desired := &sets[key].Desired
+		v := value(record)
+
+		switch change.Action {
+		case ActionCreate:
+			if !slices.Contains(desired.Values, v) {
+				desired.Values = append(desired.Values, v)
+			}
+			desired.TTL = record.TTL
+		case ActionUpdate:
+			desired.Values = []string{v}
+			desired.TTL = record.TTL
+		case ActionDelete:
+			desired.Values = slices.DeleteFunc(desired.Values, func(existing string) bool { return existing == v })
+		}
+	}
+
+	var result []RRSetChange
+	for _, key := range keys {
+		set := sets[key]
+		if set.unchanged() {
+			continue
+		}
+		result = append(result, *set)
+	}
+	return result, nil
+}
+
+func (c RRSetChange) unchanged() bool {
+	if c.Existing == nil {
+		return len(c.Desired.Values) == 0
+	}
+	return c.Existing.TTL == c.Desired.TTL && slices.Equal(c.Existing.Values, c.Desired.Values)
+}
+
+// PresentationValue returns the value of a record in the presentation format of zone files, as used by the record sets
+// of most providers: CNAME targets are fully qualified, TXT contents quoted and MX values prefixed with their priority.
+func PresentationValue(record Record) string {
+	switch record.Type {
+	case constants.RecordTypeCNAME:
+		return strings.TrimSuffix(record.Content, ".") + "."
+	case constants.RecordTypeTXT:
+		return QuoteTXT(record.Content)
+	case constants.RecordTypeMX:
+		return FormatMX(record)
+	}
+	return record.Content
+}
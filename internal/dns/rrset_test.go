@@ -0,0 +1,81 @@
+package dns
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+func TestMergeRRSets(t *testing.T) {
+	existing := map[string]*RRSet{
+		"example.com/TXT":   {Name: "example.com", Type: constants.RecordTypeTXT, TTL: 300, Values: []string{`"spf"`, `"old"`}},
+		"www.example.com/A": {Name: "www.example.com", Type: constants.RecordTypeA, TTL: 300, Values: []string{"10.0.0.1"}},
+		"db.example.com/A":  {Name: "db.example.com", Type: constants.RecordTypeA, TTL: 300, Values: []string{"10.0.0.2"}},
+	}
+	var fetched []string
+	fetch := func(name, recordType string) (*RRSet, error) {
+		fetched = append(fetched, name+"/"+recordType)
+		return existing[name+"/"+recordType], nil
+	}
+
+	changes := []Change{
+		{Action: ActionCreate, Record: Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "new", TTL: 300}},
+		{Action: ActionUpdate, Record: Record{Name: "www.example.com", Type: constants.RecordTypeA, Content: "10.0.0.3", TTL: 60}},
+		{Action: ActionDelete, Record: Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "old", TTL: 300}},
+		{Action: ActionDelete, Record: Record{Name: "db.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2"}},
+		{Action: ActionCreate, Record: Record{Name: "new.example.com", Type: constants.RecordTypeA, Content: "10.0.0.4", TTL: 300}},
+		// Creating an existing value leaves the set unchanged
+		{Action: ActionCreate, Record: Record{Name: "WWW.example.com", Type: constants.RecordTypeA, Content: "10.0.0.3", TTL: 60}},
+	}
+
+	got, err := MergeRRSets(changes, fetch, PresentationValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RRSetChange{
+		{Existing: existing["example.com/TXT"], Desired: RRSet{Name: "example.com", Type: constants.RecordTypeTXT, TTL: 300, Values: []string{`"spf"`, `"new"`}}},
+		{Existing: existing["www.example.com/A"], Desired: RRSet{Name: "www.example.com", Type: constants.RecordTypeA, TTL: 60, Values: []string{"10.0.0.3"}}},
+		{Existing: existing["db.example.com/A"], Desired: RRSet{Name: "db.example.com", Type: constants.RecordTypeA, TTL: 300, Values: []string{}}},
+		{Desired: RRSet{Name: "new.example.com", Type: constants.RecordTypeA, TTL: 300, Values: []string{"10.0.0.4"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeRRSets() = %+v, want %+v", got, want)
+	}
+	if len(fetched) != 4 {
+		t.Errorf("fetched %v, want every set once", fetched)
+	}
+
+	// Sets that end up as they were are omitted
+	noop := []Change{
+		{Action: ActionCreate, Record: Record{Name: "new.example.com", Type: constants.RecordTypeA, Content: "10.0.0.4"}},
+		{Action: ActionDelete, Record: Record{Name: "new.example.com", Type: constants.RecordTypeA, Content: "10.0.0.4"}},
+	}
+	if got, err := MergeRRSets(noop, fetch, PresentationValue); err != nil || len(got) != 0 {
+		t.Errorf("MergeRRSets() = %+v, %v, want no changes", got, err)
+	}
+
+	fetchErr := errors.New("unavailable")
+	if _, err := MergeRRSets(changes, func(string, string) (*RRSet, error) { return nil, fetchErr }, PresentationValue); !errors.Is(err, fetchErr) {
+		t.Errorf("MergeRRSets() error = %v, want %v", err, fetchErr)
+	}
+}
+
+func TestPresentationValue(t *testing.T) {
+	tests := []struct {
+		record Record
+		want   string
+	}{
+		{Record{Type: constants.RecordTypeA, Content: "10.0.0.1"}, "10.0.0.1"},
+		{Record{Type: constants.RecordTypeCNAME, Content: "example.com"}, "example.com."},
+		{Record{Type: constants.RecordTypeCNAME, Content: "example.com."}, "example.com."},
+		{Record{Type: constants.RecordTypeTXT, Content: `v=spf1 "quoted" -all`}, `"v=spf1 \"quoted\" -all"`},
+		{Record{Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10}, "10 mx.example.com."},
+	}
+	for _, tt := range tests {
+		if got := PresentationValue(tt.record); got != tt.want {
+			t.Errorf("PresentationValue(%+v) = %s, want %s", tt.record, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package dns
 
 import (
 	"log/slog"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,7 +13,8 @@ import (
 )
 
 type Handler struct {
-	Providers     map[string]Provider
+	Providers     map[string][]Backend
+	ZoneSources   []ZoneSource
 	DnsCfg        config.DNS
 	staticDomains config.Domains
 	dockerCli     *client.Client
@@ -19,6 +22,23 @@ type Handler struct {
 	LastUpdate    time.Time
 }
 
+// Backend is one of the providers a zone is reconciled against
+type Backend struct {
+	Name     string
+	Provider Provider
+	// Mirrors drop record fields they don't support instead of skipping the record
+	Mirror bool
+	// Addresses set instead of the public IP on domains without explicit address, e.g. the LAN IP for a local resolver
+	IP4 string
+	IP6 string
+}
+
+// ZoneSource discovers zones at runtime, for provider entries that are not limited to a single zone
+type ZoneSource interface {
+	// Zones returns the backends of all zones currently found. On errors, the zones found before may be returned
+	Zones() (map[string][]Backend, error)
+}
+
 type Provider interface {
 	List() ([]Record, error)
 	Get(name string, recordType string) (Record, error)
@@ -27,20 +47,61 @@ type Provider interface {
 	Delete(record Record) error
 }
 
+// BatchProvider is implemented by providers that can apply several changes to a zone in one call.
+// Providers that can't apply the changes atomically apply as many as possible and report the failed ones in the error
+type BatchProvider interface {
+	Provider
+	Apply(changes []Change) error
+}
+
+type ChangeAction string
+
+const (
+	ActionCreate ChangeAction = "create"
+	ActionUpdate ChangeAction = "update"
+	ActionDelete ChangeAction = "delete"
+)
+
+type Change struct {
+	Action ChangeAction
+	Record Record
+}
+
 type Record struct {
-	ID      string
-	Name    string
-	Content string
-	Type    string
-	Proxied bool
-	TTL     int
-	Comment string
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Type    string `json:"type"`
+	Proxied bool   `json:"proxied"`
+	TTL     int    `json:"ttl"`
+	Comment string `json:"comment"`
+	// Preference of MX records, lower values are preferred
+	Priority int `json:"priority"`
 }
 
-func NewHandler(providers map[string]Provider, dnsDefaultCfg config.DNS,
+// RecordID is the ID of a record for providers without record IDs, where a record is identified by its name and type
+func RecordID(name, recordType string) string {
+	return name + "/" + recordType
+}
+
+// RelativeName returns the name relative to the zone, e.g. 'www' for 'www.example.com', and an empty name for the apex.
+// The zone is matched case-insensitively, names outside of the zone are returned as they are.
+func RelativeName(name, zone string) string {
+	name, zone = strings.TrimSuffix(name, "."), strings.TrimSuffix(zone, ".")
+	if strings.EqualFold(name, zone) {
+		return ""
+	}
+	if suffix := "." + zone; len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
+	}
+	return name
+}
+
+func NewHandler(providers map[string][]Backend, zoneSources []ZoneSource, dnsDefaultCfg config.DNS,
 	staticDomains config.Domains, dockerCli *client.Client) Handler {
 	return Handler{
 		Providers:     providers,
+		ZoneSources:   zoneSources,
 		DnsCfg:        dnsDefaultCfg,
 		staticDomains: staticDomains,
 		dockerCli:     dockerCli,
@@ -70,10 +131,8 @@ func (h *Handler) Run() error {
 	allDomains := removeDuplicates(staticDomains, dockerDomains)
 	slog.Debug("removed duplicates", "deduped", allDomains)
 
+	var publicIp4, publicIp6 string
 	if len(allDomains) > 0 {
-		var publicIp4, publicIp6 string
-		var err error
-
 		if h.DnsCfg.EnableIP4 {
 			publicIp4, err = ip.GetPublicIP4Address()
 			if err != nil {
@@ -91,43 +150,76 @@ func (h *Handler) Run() error {
 				slog.Debug("got public IPv6 address", "ip", publicIp6)
 			}
 		}
-
-		h.setIPs(allDomains, publicIp4, publicIp6)
-		slog.Debug("set missing IPs", "domains", allDomains)
-
-		h.applyDefaults(allDomains)
-		slog.Debug("applied default values", "domains", allDomains)
 	} else {
 		slog.Info("Found no records to update")
 	}
 
-	for zone, provider := range h.Providers {
-		domains := filterDomains(allDomains, zone)
-
-		slog.Debug("starting update", "zone", zone, "domains", domains)
-		h.updateRecords(provider, domains)
-		slog.Debug("finished update", "zone", zone, "domains", domains)
+	zones := h.zones()
+	routedDomains := routeDomains(allDomains, zones)
+	// The domains of the previous run tell which records dockdns set before
+	previousDomains := routeDomains(h.LatestDomains, zones)
+	for zone, backends := range zones {
+		for _, backend := range backends {
+			// Backends with their own addresses, like a resolver in the LAN, get them instead of the public IP
+			ip4, ip6 := publicIp4, publicIp6
+			if backend.IP4 != "" {
+				ip4 = backend.IP4
+			}
+			if backend.IP6 != "" {
+				ip6 = backend.IP6
+			}
+			domains := slices.Clone(routedDomains[zone])
+			h.setIPs(domains, ip4, ip6)
+			slog.Debug("set missing IPs", "zone", zone, "backend", backend.Name, "domains", domains)
 
-		if h.DnsCfg.PurgeUnknown {
-			slog.Debug("starting purge of unknown domains", "zone", zone, "domains", domains)
-			h.purgeUnknownRecords(provider, domains)
-			slog.Debug("finished purge of unknown domains", "zone", zone, "domains", domains)
+			h.reconcile(zone, backend, domains, previousDomains[zone])
 		}
 	}
 	h.LastUpdate = time.Now()
+
+	// The public IP and the default TTL are only applied for display, planning leaves an unset TTL to the capabilities of the backend
+	h.setIPs(allDomains, publicIp4, publicIp6)
+	h.applyDefaults(allDomains)
 	h.LatestDomains = allDomains
 
 	slog.Debug("finished dns update job")
 	return nil
 }
 
+// reconcile updates the records of a single backend. A failing backend, even a panicking one,
+// does not keep the other backends of the zone from being updated.
+func (h Handler) reconcile(zone string, backend Backend, domains, previous config.Domains) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("dns update failed", "zone", zone, "backend", backend.Name, "error", r)
+		}
+	}()
+
+	slog.Debug("starting update", "zone", zone, "backend", backend.Name, "domains", domains)
+	domains = resolveTunnels(backend, domains)
+
+	// Deletions go first, so a name can switch between CNAME and A/AAAA records within one run
+	var changes []Change
+	if h.DnsCfg.PurgeUnknown {
+		changes = h.planPurge(backend.Provider, domains, previous)
+	}
+	changes = append(changes, h.planUpdates(backend, domains, previous)...)
+
+	// Update and purge are applied together, batch capable providers apply all changes of the zone at once
+	applyChanges(backend.Provider, changes)
+	routeTunnels(backend, domains, previous)
+	slog.Debug("finished update", "zone", zone, "backend", backend.Name, "domains", domains)
+}
+
 func (h Handler) setIPs(domains []config.DomainRecord, publicIp4, publicIp6 string) {
 	for i, domain := range domains {
-		// If a CNAME is configured, A and AAAA settings will be ignored. We clear the IP attributes
+		// If a CNAME is configured, A, AAAA, TXT and MX settings will be ignored. We clear the attributes
 		if strings.TrimSpace(domain.CName) != "" {
 			domain.IP4 = ""
 			domain.IP6 = ""
-		} else {
+			domain.TXT = ""
+			domain.MX = ""
+		} else if !isAddressless(domain) {
 			if strings.TrimSpace(domain.IP4) == "" && h.DnsCfg.EnableIP4 {
 				domain.IP4 = publicIp4
 			}
@@ -140,6 +232,12 @@ func (h Handler) setIPs(domains []config.DomainRecord, publicIp4, publicIp6 stri
 	}
 }
 
+// Names only carrying TXT or MX values, like _acme-challenge, don't get the public IP
+func isAddressless(domain config.DomainRecord) bool {
+	return (strings.TrimSpace(domain.TXT) != "" || strings.TrimSpace(domain.MX) != "") &&
+		strings.TrimSpace(domain.IP4) == "" && strings.TrimSpace(domain.IP6) == ""
+}
+
 func (h Handler) applyDefaults(domains []config.DomainRecord) {
 	for i, domain := range domains {
 		if domain.TTL == 0 {
@@ -149,31 +247,81 @@ func (h Handler) applyDefaults(domains []config.DomainRecord) {
 	}
 }
 
+// removeDuplicates drops label entries for names that are configured statically.
+// TXT and MX values of a label entry are merged with the static configuration, as a name can have several of them.
 func removeDuplicates(staticDomains, dockerDomains []config.DomainRecord) []config.DomainRecord {
 	result := staticDomains
 
 	for _, dockerDomain := range dockerDomains {
-		if containsDomain(staticDomains, dockerDomain.Name) {
-			slog.Info("Found duplicate domain config, using static configuration", "subdomain", dockerDomain.Name)
-		} else {
+		if !containsDomain(staticDomains, dockerDomain.Name) {
 			result = append(result, dockerDomain)
+			continue
 		}
+
+		slog.Info("Found duplicate domain config, using static configuration", "subdomain", dockerDomain.Name)
+		if strings.TrimSpace(dockerDomain.TXT) == "" && strings.TrimSpace(dockerDomain.MX) == "" {
+			continue
+		}
+		if hasCName(staticDomains, dockerDomain.Name) {
+			slog.Warn("ignoring TXT and MX labels of a name with static CNAME", "subdomain", dockerDomain.Name)
+			continue
+		}
+		result = append(result, config.DomainRecord{
+			Name:    dockerDomain.Name,
+			TXT:     dockerDomain.TXT,
+			MX:      dockerDomain.MX,
+			TTL:     dockerDomain.TTL,
+			Comment: dockerDomain.Comment,
+		})
 	}
 	return result
 }
 
-func filterDomains(allDomains config.Domains, zoneName string) config.Domains {
-	var result config.Domains
+// zones returns the configured zones together with the zones found by the zone sources.
+// Configured zones take precedence over discovered ones with the same name.
+func (h Handler) zones() map[string][]Backend {
+	zones := map[string][]Backend{}
+	maps.Copy(zones, h.Providers)
+	for _, source := range h.ZoneSources {
+		discovered, err := source.Zones()
+		if err != nil {
+			slog.Error("failed to discover zones", "error", err)
+		}
+		for zone, backends := range discovered {
+			if _, configured := zones[zone]; !configured {
+				zones[zone] = backends
+			}
+		}
+	}
+	return zones
+}
+
+// routeDomains assigns every domain to the zone with the longest name the domain is part of
+func routeDomains(allDomains config.Domains, zones map[string][]Backend) map[string]config.Domains {
+	result := map[string]config.Domains{}
 
 	for _, domain := range allDomains {
-		if strings.HasSuffix(domain.Name, zoneName) {
-			result = append(result, domain)
+		var match string
+		for zone := range zones {
+			if inZone(domain.Name, zone) && len(zone) > len(match) {
+				match = zone
+			}
+		}
+		if match == "" {
+			slog.Debug("domain is not part of any zone", "name", domain.Name)
+			continue
 		}
+		result[match] = append(result[match], domain)
 	}
 
 	return result
 }
 
+func inZone(name, zone string) bool {
+	name, zone = strings.ToLower(name), strings.ToLower(zone)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
 func containsDomain(domains []config.DomainRecord, domainName string) bool {
 	for _, domain := range domains {
 		if domain.Name == domainName {
@@ -182,3 +330,12 @@ func containsDomain(domains []config.DomainRecord, domainName string) bool {
 	}
 	return false
 }
+
+func hasCName(domains []config.DomainRecord, domainName string) bool {
+	for _, domain := range domains {
+		if domain.Name == domainName && strings.TrimSpace(domain.CName) != "" {
+			return true
+		}
+	}
+	return false
+}
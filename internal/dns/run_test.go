@@ -0,0 +1,76 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+)
+
+func TestRemoveDuplicates(t *testing.T) {
+	static := []config.DomainRecord{
+		{Name: "example.com", IP4: "10.0.0.1"},
+		{Name: "alias.example.com", CName: "example.com"},
+	}
+	docker := []config.DomainRecord{
+		{Name: "example.com", IP4: "10.0.0.2", MX: "10 mx.example.com", TXT: "v=spf1 mx -all", TTL: 60},
+		{Name: "alias.example.com", TXT: "token"},
+		{Name: "app.example.com"},
+	}
+
+	got := removeDuplicates(static, docker)
+	want := []config.DomainRecord{
+		{Name: "example.com", IP4: "10.0.0.1"},
+		{Name: "alias.example.com", CName: "example.com"},
+		{Name: "example.com", MX: "10 mx.example.com", TXT: "v=spf1 mx -all", TTL: 60},
+		{Name: "app.example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("removeDuplicates() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRouteDomains(t *testing.T) {
+	zones := map[string][]Backend{
+		"example.com":     nil,
+		"lan.example.com": nil,
+		"example.org":     nil,
+	}
+	domains := config.Domains{
+		{Name: "example.com"},
+		{Name: "www.example.com"},
+		{Name: "nas.lan.example.com"},
+		{Name: "LAN.Example.com"},
+		{Name: "notexample.com"},
+		{Name: "example.org"},
+	}
+
+	got := routeDomains(domains, zones)
+	want := map[string]config.Domains{
+		"example.com":     {{Name: "example.com"}, {Name: "www.example.com"}},
+		"lan.example.com": {{Name: "nas.lan.example.com"}, {Name: "LAN.Example.com"}},
+		"example.org":     {{Name: "example.org"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routeDomains() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRelativeName(t *testing.T) {
+	tests := []struct {
+		name, zone, want string
+	}{
+		{"example.com", "example.com", ""},
+		{"Example.COM.", "example.com", ""},
+		{"www.example.com", "example.com", "www"},
+		{"WWW.Example.com", "example.COM", "WWW"},
+		{"a.b.example.com", "example.com.", "a.b"},
+		{"notexample.com", "example.com", "notexample.com"},
+		{"www.example.org", "example.com", "www.example.org"},
+	}
+	for _, tt := range tests {
+		if got := RelativeName(tt.name, tt.zone); got != tt.want {
+			t.Errorf("RelativeName(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.want)
+		}
+	}
+}
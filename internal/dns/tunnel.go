@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/Tarow/dockdns/internal/config"
+)
+
+// TunnelProvider is implemented by providers that can expose domains through a tunnel instead of the public IP
+type TunnelProvider interface {
+	// TunnelTarget returns the name the CNAME of a tunneled domain points to
+	TunnelTarget(tunnel string) (string, error)
+	// RouteIngress adds or updates the ingress rules sending the hostnames to their services.
+	// Rules of the managed hostnames that are not part of services are removed.
+	RouteIngress(tunnel string, services map[string]string, managed []string) error
+}
+
+// resolveTunnels turns tunneled domains into proxied CNAMEs pointing to their tunnel.
+// Backends without tunnel support skip them.
+func resolveTunnels(backend Backend, domains config.Domains) config.Domains {
+	tunnelProvider, supported := backend.Provider.(TunnelProvider)
+
+	var resolved config.Domains
+	for _, domain := range domains {
+		if domain.Tunnel == "" {
+			resolved = append(resolved, domain)
+			continue
+		}
+		if !supported {
+			slog.Warn("provider does not support tunnels", "name", domain.Name, "tunnel", domain.Tunnel, "action", "skip record")
+			continue
+		}
+
+		target, err := tunnelProvider.TunnelTarget(domain.Tunnel)
+		if err != nil {
+			slog.Error("failed to look up tunnel", "name", domain.Name, "tunnel", domain.Tunnel, "action", "skip record", "error", err)
+			continue
+		}
+		domain.CName = target
+		domain.IP4 = ""
+		domain.IP6 = ""
+		domain.Proxied = true
+		resolved = append(resolved, domain)
+	}
+	return resolved
+}
+
+// routeTunnels points the ingress rules of the tunnels at the services of their domains.
+// Tunnels used in the previous run are updated as well, so rules of hostnames moved to another tunnel
+// or no longer tunneled are removed.
+func routeTunnels(backend Backend, domains, previous config.Domains) {
+	tunnelProvider, supported := backend.Provider.(TunnelProvider)
+	if !supported {
+		return
+	}
+
+	var tunnels, managed []string
+	services := map[string]map[string]string{}
+	addTunnel := func(tunnel string) {
+		if services[tunnel] == nil {
+			tunnels = append(tunnels, tunnel)
+			services[tunnel] = map[string]string{}
+		}
+	}
+
+	for _, domain := range domains {
+		if domain.Tunnel == "" {
+			continue
+		}
+		if domain.Service == "" {
+			slog.Error("no service set for tunneled domain", "name", domain.Name, "tunnel", domain.Tunnel, "action", "skip ingress rule")
+			continue
+		}
+		addTunnel(domain.Tunnel)
+		services[domain.Tunnel][domain.Name] = domain.Service
+		managed = append(managed, domain.Name)
+	}
+	for _, domain := range previous {
+		if domain.Tunnel == "" {
+			continue
+		}
+		// Domains skipped for a missing service keep their rule
+		if slices.ContainsFunc(domains, func(d config.DomainRecord) bool { return d.Name == domain.Name && d.Tunnel != "" && d.Service == "" }) {
+			continue
+		}
+		addTunnel(domain.Tunnel)
+		if !slices.Contains(managed, domain.Name) {
+			managed = append(managed, domain.Name)
+		}
+	}
+
+	for _, tunnel := range tunnels {
+		if err := tunnelProvider.RouteIngress(tunnel, services[tunnel], managed); err != nil {
+			slog.Error("failed to update tunnel ingress rules", "tunnel", tunnel, "error", err)
+		}
+	}
+}
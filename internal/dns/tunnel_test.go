@@ -0,0 +1,53 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+)
+
+type ingressCall struct {
+	services map[string]string
+	managed  []string
+}
+
+// fakeTunnelProvider records the ingress updates per tunnel
+type fakeTunnelProvider struct {
+	fakeProvider
+	routes map[string]ingressCall
+}
+
+func (p fakeTunnelProvider) TunnelTarget(tunnel string) (string, error) {
+	return tunnel + ".cfargotunnel.com", nil
+}
+
+func (p fakeTunnelProvider) RouteIngress(tunnel string, services map[string]string, managed []string) error {
+	p.routes[tunnel] = ingressCall{services: services, managed: managed}
+	return nil
+}
+
+func TestRouteTunnels(t *testing.T) {
+	provider := fakeTunnelProvider{routes: map[string]ingressCall{}}
+	domains := config.Domains{
+		{Name: "app.example.com", Tunnel: "new", Service: "http://app:8080"},
+		{Name: "db.example.com", Tunnel: "old"},
+	}
+	previous := config.Domains{
+		{Name: "app.example.com", Tunnel: "old", Service: "http://app:8080"},
+		{Name: "db.example.com", Tunnel: "old", Service: "http://db:5432"},
+		{Name: "gone.example.com", Tunnel: "other", Service: "http://gone"},
+	}
+
+	routeTunnels(Backend{Provider: provider}, domains, previous)
+
+	managed := []string{"app.example.com", "gone.example.com"}
+	want := map[string]ingressCall{
+		"new":   {services: map[string]string{"app.example.com": "http://app:8080"}, managed: managed},
+		"old":   {services: map[string]string{}, managed: managed},
+		"other": {services: map[string]string{}, managed: managed},
+	}
+	if !reflect.DeepEqual(provider.routes, want) {
+		t.Errorf("routed %+v, want %+v", provider.routes, want)
+	}
+}
@@ -0,0 +1,61 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TXT values are kept unquoted in Record.Content. Providers working with the zone file presentation format
+// convert them with QuoteTXT and UnquoteTXT, APIs taking a list of strings use SplitTXT.
+
+// A character-string within a TXT record holds at most 255 bytes
+const maxTXTStringLength = 255
+
+// SplitTXT splits a TXT value into character-strings of at most 255 bytes
+func SplitTXT(value string) []string {
+	var chunks []string
+	for len(value) > maxTXTStringLength {
+		chunks = append(chunks, value[:maxTXTStringLength])
+		value = value[maxTXTStringLength:]
+	}
+	return append(chunks, value)
+}
+
+// QuoteTXT returns the value in presentation format, long values are split into several quoted strings
+func QuoteTXT(value string) string {
+	chunks := SplitTXT(value)
+	for i, chunk := range chunks {
+		chunk = strings.ReplaceAll(chunk, `\`, `\\`)
+		chunks[i] = `"` + strings.ReplaceAll(chunk, `"`, `\"`) + `"`
+	}
+	return strings.Join(chunks, " ")
+}
+
+// UnquoteTXT joins the quoted strings of a value in presentation format. Unquoted values are returned as is
+func UnquoteTXT(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			// Either \DDD with a decimal byte value or an escaped character
+			if n, err := strconv.Atoi(value[i+1 : min(i+4, len(value))]); err == nil && i+3 < len(value) && n < 256 {
+				b.WriteByte(byte(n))
+				i += 3
+			} else {
+				b.WriteByte(value[i+1])
+				i++
+			}
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitTXT(t *testing.T) {
+	long := strings.Repeat("a", 255) + strings.Repeat("b", 255) + "c"
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{""}},
+		{"v=spf1 -all", []string{"v=spf1 -all"}},
+		{strings.Repeat("a", 255), []string{strings.Repeat("a", 255)}},
+		{long, []string{strings.Repeat("a", 255), strings.Repeat("b", 255), "c"}},
+	}
+	for _, tt := range tests {
+		if got := SplitTXT(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("SplitTXT(%d bytes) = %q, want %q", len(tt.value), got, tt.want)
+		}
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"v=spf1 -all", `"v=spf1 -all"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir`, `"C:\\dir"`},
+		{strings.Repeat("a", 256), `"` + strings.Repeat("a", 255) + `" "a"`},
+	}
+	for _, tt := range tests {
+		if got := QuoteTXT(tt.value); got != tt.want {
+			t.Errorf("QuoteTXT(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+		if got := UnquoteTXT(tt.want); got != tt.value {
+			t.Errorf("UnquoteTXT(%s) = %q, want %q", tt.want, got, tt.value)
+		}
+	}
+}
+
+func TestUnquoteTXT(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"unquoted value", "unquoted value"},
+		{` "padded" `, "padded"},
+		{`"part one" "part two"`, "part onepart two"},
+		{`"\065\066C"`, "ABC"},
+		{`"\;"`, ";"},
+		{`"trailing\"`, `trailing"`},
+	}
+	for _, tt := range tests {
+		if got := UnquoteTXT(tt.value); got != tt.want {
+			t.Errorf("UnquoteTXT(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -8,53 +8,117 @@ import (
 	"github.com/Tarow/dockdns/internal/constants"
 )
 
-func (h Handler) updateRecords(provider Provider, domains []config.DomainRecord) {
+// planUpdates returns the changes needed to create or update the records of the domains.
+// The domains of the previous run tell which TXT values were set by dockdns.
+func (h Handler) planUpdates(backend Backend, domains, previous []config.DomainRecord) []Change {
+	var changes []Change
+
+	// TXT and MX values of a name are planned together, they may be spread over several domain entries
+	var keys []valueKey
+	values := map[valueKey][]Record{}
+	invalid := map[valueKey]bool{}
+	addValues := func(key valueKey, records ...Record) {
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], records...)
+	}
+
 	for _, domain := range domains {
-		// Important: If a CNAME is set, A and AAAA records for the same name cannot be set. They will be ignored!
+		// Important: If a CNAME is set, A, AAAA, TXT and MX records for the same name cannot be set. They will be ignored!
 		if strings.TrimSpace(domain.CName) != "" {
-			h.updateRecord(provider, domain, constants.RecordTypeCNAME)
+			changes = h.planRecord(changes, backend, domain, constants.RecordTypeCNAME)
 		} else {
 			if strings.TrimSpace(domain.IP4) != "" && h.DnsCfg.EnableIP4 {
-				h.updateRecord(provider, domain, constants.RecordTypeA)
+				changes = h.planRecord(changes, backend, domain, constants.RecordTypeA)
 			}
 
 			if strings.TrimSpace(domain.IP6) != "" && h.DnsCfg.EnableIP6 {
-				h.updateRecord(provider, domain, constants.RecordTypeAAAA)
+				changes = h.planRecord(changes, backend, domain, constants.RecordTypeAAAA)
+			}
+
+			if domain.TXT != "" {
+				addValues(newValueKey(domain.Name, constants.RecordTypeTXT), txtRecord(domain))
+			}
+
+			if strings.TrimSpace(domain.MX) != "" {
+				key := newValueKey(domain.Name, constants.RecordTypeMX)
+				records, err := domainMX(domain)
+				if err != nil {
+					slog.Error("invalid mx configuration", "name", domain.Name, "action", "skip record", "error", err)
+					// Planning only the valid values would delete the existing records of the invalid ones
+					invalid[key] = true
+				}
+				addValues(key, records...)
 			}
 		}
 	}
+
+	if len(keys) == 0 {
+		return changes
+	}
+	existing, err := backend.Provider.List()
+	if err != nil {
+		slog.Error("failed to fetch existing records", "action", "skip TXT and MX records", "error", err)
+		return changes
+	}
+
+	for _, key := range keys {
+		if invalid[key] {
+			continue
+		}
+		// Other TXT values of a name are kept, unless dockdns set them in the previous run.
+		// The MX records of a name with MX configuration are managed as a whole.
+		stale := func(Record) bool { return true }
+		if key.recordType == constants.RecordTypeTXT {
+			stale = func(record Record) bool { return ownedValue(previous, record) }
+		}
+		changes = h.planValues(changes, backend, values[key], valuesOf(existing, key.name, key.recordType), stale)
+	}
+
+	return changes
 }
 
-func (h Handler) updateRecord(provider Provider, domain config.DomainRecord, recordType string) {
+// planRecord appends the change needed to bring the record of the given type in line with the domain config
+func (h Handler) planRecord(changes []Change, backend Backend, domain config.DomainRecord, recordType string) []Change {
+	provider := backend.Provider
+	newRecord := createRecord(domain, recordType)
+
+	capabilities := CapabilitiesOf(provider)
+	newRecord = h.withDefaultTTL(capabilities, newRecord)
+	if backend.Mirror {
+		newRecord = capabilities.Strip(newRecord)
+	}
+	if err := capabilities.Validate(newRecord); err != nil {
+		slog.Error("record is not supported by the provider", "name", domain.Name, "type", recordType, "action", "skip record", "error", err)
+		return changes
+	}
+
 	existingRecord, err := provider.Get(domain.Name, recordType)
 	if err != nil {
 		slog.Error("failed to fetch existing record", "name", domain.Name, "type", recordType, "action", "skip record", "error", err)
-		return
+		return changes
 	}
-	if isEqual(existingRecord, domain, recordType) {
+	if isEqual(existingRecord, newRecord) {
 		slog.Debug("No change detected, skipping update", "name", domain.Name, "type", recordType)
-		return
+		return changes
 	}
 
-	newRecord := createRecord(domain, recordType)
-	var updatedRecord Record
 	if existingRecord.ID == "" {
-		updatedRecord, err = provider.Create(newRecord)
-		if err != nil {
-			slog.Error("failed to create record", "record", newRecord, "error", err)
-			return
-		}
-		slog.Info("Successfully created new record", "name", updatedRecord.Name, "content", updatedRecord.Content, "type", updatedRecord.Type, "ttl", updatedRecord.TTL, "proxied", updatedRecord.Proxied, "comment", updatedRecord.Comment)
-	} else {
-		newRecord.ID = existingRecord.ID
-		updatedRecord, err = provider.Update(newRecord)
-		if err != nil {
-			slog.Error("failed to update record", "record", newRecord, "error", err)
-			return
-		}
-		slog.Info("Successfully updated record", "name", updatedRecord.Name, "content", updatedRecord.Content, "type", updatedRecord.Type, "ttl", updatedRecord.TTL, "proxied", updatedRecord.Proxied, "comment", updatedRecord.Comment)
+		return append(changes, Change{Action: ActionCreate, Record: newRecord})
 	}
 
+	newRecord.ID = existingRecord.ID
+	return append(changes, Change{Action: ActionUpdate, Record: newRecord})
+}
+
+// withDefaultTTL sets the default TTL on records without TTL. Providers without TTL support keep the provider default,
+// only an explicitly configured TTL is rejected for them. A default below the minimum TTL of the provider is raised to it.
+func (h Handler) withDefaultTTL(capabilities Capabilities, record Record) Record {
+	if record.TTL == 0 && capabilities.TTL {
+		record.TTL = max(h.DnsCfg.DefaultTTL, capabilities.MinTTL)
+	}
+	return record
 }
 
 func createRecord(domain config.DomainRecord, recordType string) Record {
@@ -68,27 +132,51 @@ func createRecord(domain config.DomainRecord, recordType string) Record {
 	}
 }
 
-func isEqual(record Record, domain config.DomainRecord, recordType string) bool {
-	content := domain.GetContent(recordType)
+// txtRecord returns the TXT record of the domain, TXT records are never proxied
+func txtRecord(domain config.DomainRecord) Record {
+	return Record{
+		Name:    domain.Name,
+		Content: domain.TXT,
+		Type:    constants.RecordTypeTXT,
+		TTL:     domain.TTL,
+		Comment: domain.Comment,
+	}
+}
+
+// valuesOf returns the records of the given name and type
+func valuesOf(records []Record, name, recordType string) []Record {
+	var result []Record
+	for _, record := range records {
+		if strings.EqualFold(record.Name, name) && record.Type == recordType {
+			result = append(result, record)
+		}
+	}
+	return result
+}
 
-	if !strings.EqualFold(record.Content, content) {
+func isEqual(record Record, desired Record) bool {
+	// TXT values are case sensitive
+	if desired.Type == constants.RecordTypeTXT && record.Content != desired.Content {
+		return false
+	}
+	if !strings.EqualFold(record.Content, desired.Content) {
 		return false
 	}
 
-	if !strings.EqualFold(record.Name, domain.Name) {
+	if !strings.EqualFold(record.Name, desired.Name) {
 		return false
 	}
 
-	if record.Proxied != domain.Proxied {
+	if record.Proxied != desired.Proxied {
 		return false
 	}
 
-	if record.Comment != domain.Comment {
+	if record.Comment != desired.Comment {
 		return false
 	}
 
-	// If domain is proxied, TTL will be auto, dont compare it
-	if (!record.Proxied) && record.TTL != domain.TTL {
+	// If domain is proxied, TTL will be auto, dont compare it. A TTL of 0 leaves the TTL to the provider
+	if (!record.Proxied) && desired.TTL != 0 && record.TTL != desired.TTL {
 		return false
 	}
 
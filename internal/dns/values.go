@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+// TXT and MX records are managed per value. A name usually carries several of them, often next to values
+// maintained by hand, like an SPF record next to a domain verification token.
+
+// valueKey identifies the records of a name and type that are planned together
+type valueKey struct {
+	name       string
+	recordType string
+}
+
+func newValueKey(name, recordType string) valueKey {
+	return valueKey{name: strings.ToLower(name), recordType: recordType}
+}
+
+// planValues appends the changes needed to create the desired values of a name and type.
+// Existing values are only deleted if stale reports them as managed by dockdns.
+// Changes never update an existing record, as providers working on record sets would replace all values of the name.
+func (h Handler) planValues(changes []Change, backend Backend, desired, existing []Record, stale func(Record) bool) []Change {
+	if len(desired) == 0 {
+		return changes
+	}
+
+	capabilities := CapabilitiesOf(backend.Provider)
+	var records []Record
+	for _, record := range desired {
+		record = h.withDefaultTTL(capabilities, record)
+		if backend.Mirror {
+			record = capabilities.Strip(record)
+		}
+		if err := capabilities.Validate(record); err != nil {
+			slog.Error("record is not supported by the provider", "name", record.Name, "type", record.Type, "action", "skip record", "error", err)
+			return changes
+		}
+		if !slices.ContainsFunc(records, sameValue(record)) {
+			records = append(records, record)
+		}
+	}
+
+	planned := len(changes)
+	var creates []Change
+	for _, record := range records {
+		idx := slices.IndexFunc(existing, sameValue(record))
+		switch {
+		case idx < 0:
+			creates = append(creates, Change{Action: ActionCreate, Record: record})
+		case !isEqual(existing[idx], record):
+			// Replace the value to change its TTL or comment, the deletion has to go first
+			changes = append(changes, Change{Action: ActionDelete, Record: existing[idx]}, Change{Action: ActionCreate, Record: record})
+		}
+	}
+
+	// New values are created before stale ones are deleted, so a name never runs out of e.g. mail servers
+	changes = append(changes, creates...)
+	for _, record := range existing {
+		if !slices.ContainsFunc(records, sameValue(record)) && stale(record) {
+			changes = append(changes, Change{Action: ActionDelete, Record: record})
+		}
+	}
+
+	if len(changes) == planned {
+		slog.Debug("No change detected, skipping update", "name", records[0].Name, "type", records[0].Type)
+	}
+	return changes
+}
+
+// ownedValue reports whether the value was set by dockdns, i.e. it was part of the domains of the previous run.
+// Ownership is not persisted, values dropped from the configuration while dockdns was not running are kept.
+func ownedValue(previous []config.DomainRecord, record Record) bool {
+	for _, domain := range previous {
+		if !strings.EqualFold(domain.Name, record.Name) || strings.TrimSpace(domain.CName) != "" {
+			continue
+		}
+
+		var values []Record
+		switch record.Type {
+		case constants.RecordTypeTXT:
+			if domain.TXT != "" {
+				values = append(values, txtRecord(domain))
+			}
+		case constants.RecordTypeMX:
+			if strings.TrimSpace(domain.MX) != "" {
+				values, _ = domainMX(domain)
+			}
+		}
+		if slices.ContainsFunc(values, sameValue(record)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue matches records of the same name and type holding the same value
+func sameValue(record Record) func(Record) bool {
+	return func(other Record) bool {
+		if !strings.EqualFold(record.Name, other.Name) || record.Type != other.Type {
+			return false
+		}
+		switch record.Type {
+		case constants.RecordTypeTXT:
+			// TXT values are case sensitive
+			return record.Content == other.Content
+		case constants.RecordTypeMX:
+			return strings.EqualFold(record.Content, other.Content) && record.Priority == other.Priority
+		}
+		return strings.EqualFold(record.Content, other.Content)
+	}
+}
+
+// isValueType reports whether records of the type are managed per value
+func isValueType(recordType string) bool {
+	return recordType == constants.RecordTypeTXT || recordType == constants.RecordTypeMX
+}
@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/constants"
+)
+
+// fakeProvider serves a fixed set of records and supports all record fields
+type fakeProvider struct {
+	records []Record
+}
+
+func (p fakeProvider) List() ([]Record, error) {
+	return p.records, nil
+}
+
+func (p fakeProvider) Get(name, recordType string) (Record, error) {
+	for _, record := range p.records {
+		if record.Name == name && record.Type == recordType {
+			return record, nil
+		}
+	}
+	return Record{}, nil
+}
+
+func (p fakeProvider) Create(record Record) (Record, error) { return record, nil }
+func (p fakeProvider) Update(record Record) (Record, error) { return record, nil }
+func (p fakeProvider) Delete(record Record) error           { return nil }
+
+func txt(name, value string) Record {
+	return Record{ID: name + value, Name: name, Content: value, Type: constants.RecordTypeTXT, TTL: 300}
+}
+
+func aRecord(name, ip string) Record {
+	return Record{ID: name + ip, Name: name, Content: ip, Type: constants.RecordTypeA, TTL: 300}
+}
+
+func TestPlanUpdatesTXT(t *testing.T) {
+	spf := txt("example.com", "v=spf1 mx -all")
+	verification := txt("example.com", "verification=abc")
+	oldToken := txt("example.com", "token=old")
+
+	tests := []struct {
+		name     string
+		existing []Record
+		domains  []config.DomainRecord
+		previous []config.DomainRecord
+		want     []Change
+	}{
+		{
+			name:     "creates a missing value next to unrelated ones",
+			existing: []Record{spf},
+			domains:  []config.DomainRecord{{Name: "example.com", TXT: "verification=abc"}},
+			want:     []Change{{Action: ActionCreate, Record: Record{Name: "example.com", Content: "verification=abc", Type: constants.RecordTypeTXT, TTL: 300}}},
+		},
+		{
+			name:     "keeps existing values",
+			existing: []Record{spf, verification},
+			domains:  []config.DomainRecord{{Name: "example.com", TXT: "verification=abc"}, {Name: "example.com", TXT: "v=spf1 mx -all"}},
+		},
+		{
+			name:     "replaces a value set in the previous run",
+			existing: []Record{spf, oldToken},
+			domains:  []config.DomainRecord{{Name: "example.com", TXT: "token=new"}},
+			previous: []config.DomainRecord{{Name: "example.com", TXT: "token=old"}},
+			want: []Change{
+				{Action: ActionCreate, Record: Record{Name: "example.com", Content: "token=new", Type: constants.RecordTypeTXT, TTL: 300}},
+				{Action: ActionDelete, Record: oldToken},
+			},
+		},
+		{
+			name:     "replaces a value with a different ttl",
+			existing: []Record{spf},
+			domains:  []config.DomainRecord{{Name: "example.com", TXT: "v=spf1 mx -all", TTL: 60}},
+			want: []Change{
+				{Action: ActionDelete, Record: spf},
+				{Action: ActionCreate, Record: Record{Name: "example.com", Content: "v=spf1 mx -all", Type: constants.RecordTypeTXT, TTL: 60}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler{DnsCfg: config.DNS{DefaultTTL: 300}}
+			backend := Backend{Provider: fakeProvider{records: tt.existing}}
+
+			got := h.planUpdates(backend, tt.domains, tt.previous)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planUpdates() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mx(name string, priority int, target string) Record {
+	return Record{ID: name + target, Name: name, Content: target, Type: constants.RecordTypeMX, Priority: priority, TTL: 300}
+}
+
+func TestPlanUpdatesMX(t *testing.T) {
+	mx1 := mx("example.com", 10, "mx1.example.com")
+	mx2 := mx("example.com", 20, "mx2.example.com")
+
+	h := Handler{DnsCfg: config.DNS{DefaultTTL: 300}}
+	backend := Backend{Provider: fakeProvider{records: []Record{mx1, mx2, txt("example.com", "v=spf1 mx -all")}}}
+	domains := []config.DomainRecord{{Name: "example.com", MX: "10 mx1.example.com, 30 mx3.example.com"}}
+
+	got := h.planUpdates(backend, domains, nil)
+	want := []Change{
+		{Action: ActionCreate, Record: Record{Name: "example.com", Content: "mx3.example.com", Type: constants.RecordTypeMX, Priority: 30, TTL: 300}},
+		{Action: ActionDelete, Record: mx2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planUpdates() = %+v, want %+v", got, want)
+	}
+
+	// An invalid value leaves all MX records of the name untouched
+	domains = append(domains, config.DomainRecord{Name: "example.com", MX: "mx4.example.com"})
+	if got := h.planUpdates(backend, domains, nil); len(got) != 0 {
+		t.Errorf("planUpdates() = %+v, want no changes", got)
+	}
+}
@@ -0,0 +1,178 @@
+package adguardhome
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME},
+}
+
+type adguardProvider struct {
+	client jsonapi.Client
+	zone   string
+}
+
+type rewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+func New(apiURL, username, password, zone string) (adguardProvider, error) {
+	if apiURL == "" {
+		return adguardProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+
+	return adguardProvider{
+		client: jsonapi.New("adguard home", apiURL, func(req *http.Request) {
+			if username != "" || password != "" {
+				req.SetBasicAuth(username, password)
+			}
+		}),
+		zone: zone,
+	}, nil
+}
+
+func (p adguardProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List returns the rewrites below the zone, rewrites of other domains are left untouched
+func (p adguardProvider) List() ([]dns.Record, error) {
+	rewrites, err := p.rewrites()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, rw := range rewrites {
+		if !p.inZone(rw.Domain) {
+			continue
+		}
+		// Answers 'A' and 'AAAA' keep the upstream records and are not managed by dockdns
+		if record, ok := mapRecord(rw); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p adguardProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p adguardProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	if err := p.client.Do(http.MethodPost, "/control/rewrite/add", toRewrite(record), nil); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p adguardProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	existing, err := p.Get(record.Name, record.Type)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	if existing.ID == "" {
+		return p.Create(record)
+	}
+
+	body := struct {
+		Target rewrite `json:"target"`
+		Update rewrite `json:"update"`
+	}{
+		Target: toRewrite(existing),
+		Update: toRewrite(record),
+	}
+	if err := p.client.Do(http.MethodPut, "/control/rewrite/update", body, nil); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p adguardProvider) Delete(record dns.Record) error {
+	if !p.inZone(record.Name) {
+		return fmt.Errorf("refusing to delete %s, it is not part of zone %s", record.Name, p.zone)
+	}
+	return p.client.Do(http.MethodPost, "/control/rewrite/delete", toRewrite(record), nil)
+}
+
+func (p adguardProvider) validate(record dns.Record) error {
+	if err := capabilities.Validate(record); err != nil {
+		return fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+	if !p.inZone(record.Name) {
+		return fmt.Errorf("%s is not part of zone %s", record.Name, p.zone)
+	}
+	return nil
+}
+
+func (p adguardProvider) inZone(name string) bool {
+	return strings.EqualFold(name, p.zone) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(p.zone))
+}
+
+func (p adguardProvider) rewrites() ([]rewrite, error) {
+	var rewrites []rewrite
+	if err := p.client.Do(http.MethodGet, "/control/rewrite/list", nil, &rewrites); err != nil {
+		return nil, err
+	}
+	return rewrites, nil
+}
+
+func toRewrite(record dns.Record) rewrite {
+	return rewrite{
+		Domain: record.Name,
+		Answer: record.Content,
+	}
+}
+
+func mapRecord(rw rewrite) (dns.Record, bool) {
+	var recordType string
+	ip := net.ParseIP(rw.Answer)
+	switch {
+	case rw.Answer == constants.RecordTypeA || rw.Answer == constants.RecordTypeAAAA:
+		return dns.Record{}, false
+	case ip == nil:
+		recordType = constants.RecordTypeCNAME
+	case ip.To4() != nil:
+		recordType = constants.RecordTypeA
+	default:
+		recordType = constants.RecordTypeAAAA
+	}
+
+	return dns.Record{
+		ID:      dns.RecordID(rw.Domain, recordType),
+		Name:    rw.Domain,
+		Type:    recordType,
+		Content: rw.Answer,
+	}, true
+}
@@ -0,0 +1,149 @@
+package adguardhome
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeAdGuard serves the DNS rewrites and applies changes like AdGuard Home does
+type fakeAdGuard struct {
+	rewrites []rewrite
+}
+
+func (f *fakeAdGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, _ := r.BasicAuth(); username != "admin" || password != "secret" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /control/rewrite/list":
+		json.NewEncoder(w).Encode(f.rewrites)
+	case "POST /control/rewrite/add":
+		var body rewrite
+		json.NewDecoder(r.Body).Decode(&body)
+		f.rewrites = append(f.rewrites, body)
+	case "POST /control/rewrite/delete":
+		var body rewrite
+		json.NewDecoder(r.Body).Decode(&body)
+		idx := slices.Index(f.rewrites, body)
+		if idx < 0 {
+			http.Error(w, "rewrite not found", http.StatusBadRequest)
+			return
+		}
+		f.rewrites = slices.Delete(f.rewrites, idx, idx+1)
+	case "PUT /control/rewrite/update":
+		var body struct {
+			Target rewrite `json:"target"`
+			Update rewrite `json:"update"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		idx := slices.Index(f.rewrites, body.Target)
+		if idx < 0 {
+			http.Error(w, "rewrite not found", http.StatusBadRequest)
+			return
+		}
+		f.rewrites[idx] = body.Update
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeAdGuard) adguardProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "admin", "secret", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	fake := &fakeAdGuard{rewrites: []rewrite{
+		{Domain: "nas.home.lan", Answer: "10.0.0.1"},
+		{Domain: "nas.home.lan", Answer: "fd00::1"},
+		{Domain: "media.home.lan", Answer: "nas.home.lan"},
+		// Keeps the upstream records, not managed by dockdns
+		{Domain: "upstream.home.lan", Answer: "A"},
+		{Domain: "router.other.lan", Answer: "10.0.0.9"},
+	}}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dns.Record{
+		{ID: "nas.home.lan/A", Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.1"},
+		{ID: "nas.home.lan/AAAA", Name: "nas.home.lan", Type: constants.RecordTypeAAAA, Content: "fd00::1"},
+		{ID: "media.home.lan/CNAME", Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "nas.home.lan"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	fake := &fakeAdGuard{rewrites: []rewrite{{Domain: "nas.home.lan", Answer: "10.0.0.1"}}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Create(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := p.Update(dns.Record{Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.3"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// Updating a missing rewrite creates it
+	if _, err := p.Update(dns.Record{Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "nas.home.lan"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	want := []rewrite{{Domain: "nas.home.lan", Answer: "10.0.0.3"}, {Domain: "media.home.lan", Answer: "nas.home.lan"}}
+	if !reflect.DeepEqual(fake.rewrites, want) {
+		t.Errorf("rewrites = %+v, want %+v", fake.rewrites, want)
+	}
+}
+
+func TestRejectsUnsupportedRecords(t *testing.T) {
+	fake := &fakeAdGuard{rewrites: []rewrite{{Domain: "router.other.lan", Answer: "10.0.0.9"}}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Create(dns.Record{Name: "app.other.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err == nil {
+		t.Error("Create() accepted a record outside of the zone")
+	}
+	if err := p.Delete(dns.Record{Name: "router.other.lan", Type: constants.RecordTypeA, Content: "10.0.0.9"}); err == nil {
+		t.Error("Delete() accepted a record outside of the zone")
+	}
+	if _, err := p.Create(dns.Record{Name: "home.lan", Type: constants.RecordTypeTXT, Content: "token=1"}); err == nil {
+		t.Error("Create() accepted a TXT record")
+	}
+	if len(fake.rewrites) != 1 {
+		t.Errorf("rewrites = %+v, want them unchanged", fake.rewrites)
+	}
+}
+
+func TestWrongCredentials(t *testing.T) {
+	server := httptest.NewServer(&fakeAdGuard{})
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "admin", "wrong", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf("List() error = %v, want 403 Forbidden", err)
+	}
+}
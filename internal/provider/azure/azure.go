@@ -0,0 +1,453 @@
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const (
+	defaultApiURL  = "https://management.azure.com"
+	defaultAuthURL = "https://login.microsoftonline.com"
+	apiVersion     = "2018-05-01"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type azureProvider struct {
+	apiURL       string
+	authURL      string
+	tenantID     string
+	clientID     string
+	clientSecret string
+	zonePath     string
+	zone         string
+	client       *http.Client
+	token        *token
+}
+
+// token is shared between all copies of the provider and renewed shortly before it expires
+type token struct {
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type recordSet struct {
+	Name       string `json:"name,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Etag       string `json:"etag,omitempty"`
+	Properties struct {
+		TTL         int          `json:"TTL"`
+		FQDN        string       `json:"fqdn,omitempty"`
+		ARecords    []aRecord    `json:"ARecords,omitempty"`
+		AAAARecords []aaaaRecord `json:"AAAARecords,omitempty"`
+		CNAMERecord *cnameRecord `json:"CNAMERecord,omitempty"`
+		TXTRecords  []txtRecord  `json:"TXTRecords,omitempty"`
+		MXRecords   []mxRecord   `json:"MXRecords,omitempty"`
+	} `json:"properties"`
+}
+
+type aRecord struct {
+	IPv4Address string `json:"ipv4Address"`
+}
+
+type aaaaRecord struct {
+	IPv6Address string `json:"ipv6Address"`
+}
+
+type cnameRecord struct {
+	CName string `json:"cname"`
+}
+
+// A TXT value is split into strings of at most 255 characters
+type txtRecord struct {
+	Value []string `json:"value"`
+}
+
+type mxRecord struct {
+	Preference int    `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+func New(apiURL, authURL, tenantID, clientID, clientSecret, subscriptionID, resourceGroup, zone string) (azureProvider, error) {
+	if tenantID == "" || clientID == "" || clientSecret == "" {
+		return azureProvider{}, fmt.Errorf("tenant id, client id and client secret are required for zone %s", zone)
+	}
+	if subscriptionID == "" || resourceGroup == "" {
+		return azureProvider{}, fmt.Errorf("subscription id and resource group are required for zone %s", zone)
+	}
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+	if authURL == "" {
+		authURL = defaultAuthURL
+	}
+
+	return azureProvider{
+		apiURL:       strings.TrimSuffix(apiURL, "/"),
+		authURL:      strings.TrimSuffix(authURL, "/"),
+		tenantID:     tenantID,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		zonePath: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/dnsZones/%s",
+			url.PathEscape(subscriptionID), url.PathEscape(resourceGroup), url.PathEscape(zone)),
+		zone:   zone,
+		client: jsonapi.NewHTTPClient(),
+		token:  &token{},
+	}, nil
+}
+
+func (p azureProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p azureProvider) List() ([]dns.Record, error) {
+	var records []dns.Record
+
+	next := p.apiURL + p.zonePath + "/recordsets?api-version=" + apiVersion
+	for next != "" {
+		var page struct {
+			Value    []recordSet `json:"value"`
+			NextLink string      `json:"nextLink"`
+		}
+		if err := p.do(http.MethodGet, next, nil, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, set := range page.Value {
+			records = append(records, p.mapRecords(set)...)
+		}
+		next = page.NextLink
+	}
+
+	return records, nil
+}
+
+func (p azureProvider) Get(domain, recordType string) (dns.Record, error) {
+	set, err := p.recordSet(domain, recordType)
+	if err != nil || set == nil {
+		return dns.Record{}, err
+	}
+
+	records := p.mapRecords(*set)
+	if len(records) == 0 {
+		return dns.Record{}, nil
+	}
+	return records[0], nil
+}
+
+// Create adds the value to the record set of the name and type
+func (p azureProvider) Create(record dns.Record) (dns.Record, error) {
+	return p.modify(record, func(set *recordSet) {
+		if !slices.Contains(values(*set), toValue(record)) {
+			setValues(set, record.Type, append(values(*set), toValue(record)))
+		}
+	})
+}
+
+// Update replaces all values of the record set with the value of the record
+func (p azureProvider) Update(record dns.Record) (dns.Record, error) {
+	return p.modify(record, func(set *recordSet) {
+		setValues(set, record.Type, []string{toValue(record)})
+	})
+}
+
+// Delete removes the value from the record set. The set is deleted when no value is left
+func (p azureProvider) Delete(record dns.Record) error {
+	set, err := p.recordSet(record.Name, record.Type)
+	if err != nil || set == nil {
+		return err
+	}
+
+	current := values(*set)
+	remaining := slices.DeleteFunc(slices.Clone(current), func(v string) bool { return v == toValue(record) })
+	switch {
+	case len(remaining) == len(current):
+		return nil
+	case len(remaining) > 0:
+		setValues(set, record.Type, remaining)
+		return p.put(record.Name, record.Type, *set, ifMatch(set.Etag))
+	default:
+		return p.do(http.MethodDelete, p.recordSetURL(record.Name, record.Type), nil, ifMatch(set.Etag), nil)
+	}
+}
+
+// modify applies the change to the current record set and writes it back.
+// The etag makes sure the set was not changed by someone else in between.
+func (p azureProvider) modify(record dns.Record, change func(*recordSet)) (dns.Record, error) {
+	if err := capabilities.Validate(record); err != nil {
+		return dns.Record{}, fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+
+	set, err := p.recordSet(record.Name, record.Type)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	header := http.Header{"If-None-Match": {"*"}}
+	if set != nil {
+		header = ifMatch(set.Etag)
+	} else {
+		set = &recordSet{}
+	}
+
+	change(set)
+	set.Properties.TTL = record.TTL
+
+	if err := p.put(record.Name, record.Type, *set, header); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p azureProvider) put(domain, recordType string, set recordSet, header http.Header) error {
+	// Only the properties are sent, the read-only fields are set by Azure
+	body := recordSet{Properties: set.Properties}
+	body.Properties.FQDN = ""
+	return p.do(http.MethodPut, p.recordSetURL(domain, recordType), body, header, nil)
+}
+
+// recordSet returns the record set with the given name and type, or nil if it does not exist
+func (p azureProvider) recordSet(domain, recordType string) (*recordSet, error) {
+	var set recordSet
+	err := p.do(http.MethodGet, p.recordSetURL(domain, recordType), nil, nil, &set)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (p azureProvider) recordSetURL(domain, recordType string) string {
+	return fmt.Sprintf("%s%s/%s/%s?api-version=%s", p.apiURL, p.zonePath, recordType, url.PathEscape(p.relativeName(domain)), apiVersion)
+}
+
+// relativeName returns the name relative to the zone, '@' is used for the zone apex
+func (p azureProvider) relativeName(domain string) string {
+	if name := dns.RelativeName(domain, p.zone); name != "" {
+		return name
+	}
+	return "@"
+}
+
+var errNotFound = errors.New("not found")
+
+func (p azureProvider) do(method, reqURL string, body any, header http.Header, result any) error {
+	accessToken, err := p.accessToken()
+	if err != nil {
+		return err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, reqURL, reqBody)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(resp)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// accessToken returns a token from the client credentials flow
+func (p azureProvider) accessToken() (string, error) {
+	p.token.mu.Lock()
+	defer p.token.mu.Unlock()
+
+	if p.token.accessToken != "" && time.Now().Add(time.Minute).Before(p.token.expiresAt) {
+		return p.token.accessToken, nil
+	}
+
+	resp, err := p.client.PostForm(p.authURL+"/"+url.PathEscape(p.tenantID)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"scope":         {p.apiURL + "/.default"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not decode azure token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("azure authentication failed: %s %s", result.Error, result.ErrorDescription)
+	}
+
+	p.token.accessToken = result.AccessToken
+	p.token.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return p.token.accessToken, nil
+}
+
+func apiError(resp *http.Response) error {
+	var apiErr struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Errorf("azure api returned %s: %s: %s", resp.Status, apiErr.Error.Code, apiErr.Error.Message)
+	}
+	return fmt.Errorf("azure api returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// mapRecords returns one record per value of the record set
+func (p azureProvider) mapRecords(set recordSet) []dns.Record {
+	recordType := set.Type[strings.LastIndex(set.Type, "/")+1:]
+	if !capabilities.Supports(recordType) {
+		return nil
+	}
+
+	name := strings.TrimSuffix(set.Properties.FQDN, ".")
+	if name == "" {
+		name = p.zone
+		if set.Name != "@" {
+			name = set.Name + "." + p.zone
+		}
+	}
+
+	var records []dns.Record
+	for _, value := range values(set) {
+		var priority int
+		switch recordType {
+		case constants.RecordTypeTXT:
+			// TXT values are taken as they are, a trailing dot is part of the text
+		case constants.RecordTypeMX:
+			priority, value = dns.ParseMX(value)
+		default:
+			value = strings.TrimSuffix(value, ".")
+		}
+		records = append(records, dns.Record{
+			ID:       dns.RecordID(name, recordType),
+			Name:     name,
+			Type:     recordType,
+			Content:  value,
+			TTL:      set.Properties.TTL,
+			Priority: priority,
+		})
+	}
+	return records
+}
+
+func values(set recordSet) []string {
+	var values []string
+	for _, r := range set.Properties.ARecords {
+		values = append(values, r.IPv4Address)
+	}
+	for _, r := range set.Properties.AAAARecords {
+		values = append(values, r.IPv6Address)
+	}
+	if set.Properties.CNAMERecord != nil {
+		values = append(values, set.Properties.CNAMERecord.CName)
+	}
+	for _, r := range set.Properties.TXTRecords {
+		values = append(values, strings.Join(r.Value, ""))
+	}
+	// MX values are compared in presentation format, e.g. '10 mail.example.com.'
+	for _, r := range set.Properties.MXRecords {
+		values = append(values, dns.FormatMX(dns.Record{Priority: r.Preference, Content: r.Exchange}))
+	}
+	return values
+}
+
+func setValues(set *recordSet, recordType string, values []string) {
+	switch recordType {
+	case constants.RecordTypeA:
+		set.Properties.ARecords = nil
+		for _, v := range values {
+			set.Properties.ARecords = append(set.Properties.ARecords, aRecord{IPv4Address: v})
+		}
+	case constants.RecordTypeAAAA:
+		set.Properties.AAAARecords = nil
+		for _, v := range values {
+			set.Properties.AAAARecords = append(set.Properties.AAAARecords, aaaaRecord{IPv6Address: v})
+		}
+	case constants.RecordTypeCNAME:
+		// A CNAME record set can only hold one value
+		set.Properties.CNAMERecord = &cnameRecord{CName: values[len(values)-1]}
+	case constants.RecordTypeTXT:
+		set.Properties.TXTRecords = nil
+		for _, v := range values {
+			set.Properties.TXTRecords = append(set.Properties.TXTRecords, txtRecord{Value: dns.SplitTXT(v)})
+		}
+	case constants.RecordTypeMX:
+		set.Properties.MXRecords = nil
+		for _, v := range values {
+			preference, exchange := dns.ParseMX(v)
+			set.Properties.MXRecords = append(set.Properties.MXRecords, mxRecord{Preference: preference, Exchange: exchange + "."})
+		}
+	}
+}
+
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
+
+func toValue(record dns.Record) string {
+	switch record.Type {
+	case constants.RecordTypeTXT:
+		return record.Content
+	case constants.RecordTypeMX:
+		return dns.FormatMX(record)
+	}
+	return strings.TrimSuffix(record.Content, ".")
+}
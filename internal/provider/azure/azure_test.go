@@ -0,0 +1,293 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const zonePath = "/subscriptions/sub-1/resourceGroups/rg-1/providers/Microsoft.Network/dnsZones/example.com"
+
+// fakeAzure issues access tokens and serves the record sets of the zone example.com.
+// Writes are checked against the etag of the record set like Azure does.
+type fakeAzure struct {
+	sets   []recordSet
+	etag   int
+	logins int
+	// conflict changes the etag of a record set after it was read
+	conflict bool
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/tenant-1/oauth2/v2.0/token" {
+		if r.PostFormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid_client", "error_description": "Invalid client secret provided."})
+			return
+		}
+		f.logins++
+		json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("token-%d", f.logins), "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", f.logins) || r.URL.Query().Get("api-version") != apiVersion {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == zonePath+"/recordsets" {
+		f.list(w, r)
+		return
+	}
+
+	recordType, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, zonePath+"/"), "/")
+	idx := slices.IndexFunc(f.sets, func(set recordSet) bool {
+		return strings.EqualFold(set.Name, name) && strings.HasSuffix(set.Type, "/"+recordType)
+	})
+	if idx >= 0 && r.Header.Get("If-None-Match") == "*" ||
+		idx >= 0 && r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != f.sets[idx].Etag ||
+		idx < 0 && r.Header.Get("If-Match") != "" {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": "PreconditionFailed", "message": "The condition specified using HTTP conditional header(s) is not met."}})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && idx >= 0:
+		json.NewEncoder(w).Encode(f.sets[idx])
+		if f.conflict {
+			f.etag++
+			f.sets[idx].Etag = fmt.Sprintf("etag-%d", f.etag)
+		}
+	case r.Method == http.MethodPut:
+		var set recordSet
+		json.NewDecoder(r.Body).Decode(&set)
+		if set.Name != "" || set.Type != "" || set.Etag != "" || set.Properties.FQDN != "" {
+			http.Error(w, "read-only fields must not be set", http.StatusBadRequest)
+			return
+		}
+		f.etag++
+		set.Name, set.Type, set.Etag = name, "Microsoft.Network/dnszones/"+recordType, fmt.Sprintf("etag-%d", f.etag)
+		if idx >= 0 {
+			f.sets[idx] = set
+		} else {
+			f.sets = append(f.sets, set)
+		}
+		json.NewEncoder(w).Encode(set)
+	case r.Method == http.MethodDelete && idx >= 0:
+		f.sets = slices.Delete(f.sets, idx, idx+1)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": "NotFound", "message": "The resource record was not found."}})
+	}
+}
+
+// list serves the record sets in pages of two sets
+func (f *fakeAzure) list(w http.ResponseWriter, r *http.Request) {
+	skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+	page := f.sets[min(skip, len(f.sets)):min(skip+2, len(f.sets))]
+	var nextLink string
+	if skip+2 < len(f.sets) {
+		nextLink = fmt.Sprintf("http://%s%s?api-version=%s&$skip=%d", r.Host, r.URL.Path, apiVersion, skip+2)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"value": page, "nextLink": nextLink})
+}
+
+// find returns the record set with the name and type, or nil if it does not exist
+func (f *fakeAzure) find(name, recordType string) *recordSet {
+	for i, set := range f.sets {
+		if strings.EqualFold(set.Name, name) && strings.HasSuffix(set.Type, "/"+recordType) {
+			return &f.sets[i]
+		}
+	}
+	return nil
+}
+
+func testSet(name, recordType string, ttl int, values ...string) recordSet {
+	set := recordSet{Name: name, Type: "Microsoft.Network/dnszones/" + recordType, Etag: "etag-0"}
+	set.Properties.TTL = ttl
+	setValues(&set, recordType, values)
+	return set
+}
+
+func newTestProvider(t *testing.T, fake *fakeAzure) azureProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, server.URL, "tenant-1", "client-1", "secret", "sub-1", "rg-1", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	longTXT := strings.Repeat("a", 300)
+	fake := &fakeAzure{sets: []recordSet{
+		testSet("@", "NS", 172800, "ns1-01.azure-dns.com."),
+		testSet("@", constants.RecordTypeA, 300, "10.0.0.1", "10.0.0.2"),
+		testSet("www", constants.RecordTypeCNAME, 60, "example.com."),
+		testSet("@", constants.RecordTypeTXT, 300, longTXT),
+		testSet("mail", constants.RecordTypeMX, 3600, "10 mx.example.com."),
+	}}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	// The records of all pages are listed, one record per value of a set
+	want := []dns.Record{
+		{ID: "example.com/A", Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300},
+		{ID: "example.com/A", Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 300},
+		{ID: "www.example.com/CNAME", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 60},
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: longTXT, TTL: 300},
+		{ID: "mail.example.com/MX", Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 3600, Priority: 10},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	record, err := p.Get("WWW.Example.com", constants.RecordTypeCNAME)
+	if err != nil || record != want[2] {
+		t.Errorf("Get() = %+v, %v, want %+v", record, err, want[2])
+	}
+	record, err = p.Get("missing.example.com", constants.RecordTypeA)
+	if err != nil || record.ID != "" {
+		t.Errorf("Get() = %+v, %v, want no record", record, err)
+	}
+}
+
+func TestCreateMergesIntoRecordSet(t *testing.T) {
+	fake := &fakeAzure{sets: []recordSet{testSet("app", constants.RecordTypeA, 300, "10.0.0.1")}}
+	p := newTestProvider(t, fake)
+
+	created, err := p.Create(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 300})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID != "app.example.com/A" {
+		t.Errorf("Create() ID = %s, want app.example.com/A", created.ID)
+	}
+	// Creating an existing value keeps the set as it is
+	if _, err := p.Create(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 300}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// The apex is written as '@', regardless of the case of the name
+	if _, err := p.Create(dns.Record{Name: "Example.COM", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 3600, Priority: 10}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if got, want := values(*fake.find("app", constants.RecordTypeA)), []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("A values = %q, want %q", got, want)
+	}
+	apex := fake.find("@", constants.RecordTypeMX)
+	if apex == nil {
+		t.Fatalf("sets = %+v, want an MX set at the apex", fake.sets)
+	}
+	if want := []mxRecord{{Preference: 10, Exchange: "mx.example.com."}}; !reflect.DeepEqual(apex.Properties.MXRecords, want) || apex.Properties.TTL != 3600 {
+		t.Errorf("MX set = %+v, want %+v with TTL 3600", apex.Properties, want)
+	}
+}
+
+func TestUpdateReplacesRecordSet(t *testing.T) {
+	fake := &fakeAzure{sets: []recordSet{testSet("app", constants.RecordTypeA, 300, "10.0.0.1", "10.0.0.2")}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Update(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.3", TTL: 60}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	set := fake.find("app", constants.RecordTypeA)
+	if got, want := values(*set), []string{"10.0.0.3"}; !reflect.DeepEqual(got, want) || set.Properties.TTL != 60 {
+		t.Errorf("A values = %q with TTL %d, want %q with TTL 60", got, set.Properties.TTL, want)
+	}
+
+	// Updating a missing set creates it
+	if _, err := p.Update(dns.Record{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 60}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if set := fake.find("www", constants.RecordTypeCNAME); set == nil || set.Properties.CNAMERecord.CName != "example.com" {
+		t.Errorf("sets = %+v, want a CNAME set for www", fake.sets)
+	}
+}
+
+func TestDeleteKeepsOtherValues(t *testing.T) {
+	fake := &fakeAzure{sets: []recordSet{testSet("mail", constants.RecordTypeMX, 3600, "10 mx1.example.com.", "20 mx2.example.com.")}}
+	p := newTestProvider(t, fake)
+
+	if err := p.Delete(dns.Record{Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx1.example.com", Priority: 10}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	// The remaining value keeps the TTL of the set
+	set := fake.find("mail", constants.RecordTypeMX)
+	if want := []mxRecord{{Preference: 20, Exchange: "mx2.example.com."}}; !reflect.DeepEqual(set.Properties.MXRecords, want) || set.Properties.TTL != 3600 {
+		t.Errorf("MX set = %+v, want %+v with TTL 3600", set.Properties, want)
+	}
+
+	// Deleting an unknown value or set is a no-op
+	if err := p.Delete(dns.Record{Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx3.example.com", Priority: 30}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := p.Delete(dns.Record{Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx2.example.com", Priority: 20}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(fake.sets) != 0 {
+		t.Errorf("sets = %+v, want none", fake.sets)
+	}
+}
+
+func TestConcurrentChange(t *testing.T) {
+	fake := &fakeAzure{sets: []recordSet{testSet("app", constants.RecordTypeA, 300, "10.0.0.1")}, conflict: true}
+	p := newTestProvider(t, fake)
+
+	// A set changed between reading and writing it is not overwritten
+	if _, err := p.Create(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 300}); err == nil || !strings.Contains(err.Error(), "PreconditionFailed") {
+		t.Errorf("Create() error = %v, want PreconditionFailed", err)
+	}
+	if got, want := values(*fake.find("app", constants.RecordTypeA)), []string{"10.0.0.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("A values = %q, want %q", got, want)
+	}
+}
+
+func TestAccessToken(t *testing.T) {
+	fake := &fakeAzure{}
+	p := newTestProvider(t, fake)
+
+	// The token is reused across calls and copies of the provider
+	for range 3 {
+		if _, err := p.List(); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+	}
+	if fake.logins != 1 {
+		t.Errorf("logged in %d times, want 1", fake.logins)
+	}
+
+	// A token about to expire is renewed
+	p.token.expiresAt = time.Now().Add(30 * time.Second)
+	if _, err := p.List(); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if fake.logins != 2 {
+		t.Errorf("logged in %d times, want 2", fake.logins)
+	}
+
+	p.clientSecret = "wrong"
+	p.token.accessToken = ""
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "Invalid client secret provided.") {
+		t.Errorf("List() error = %v, want a failed authentication", err)
+	}
+}
@@ -13,36 +13,79 @@ import (
 	"github.com/cloudflare/cloudflare-go/v7/zones"
 )
 
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	Proxied:     true,
+	Comment:     true,
+	TTL:         true,
+}
+
 type cloudflareProvider struct {
 	apiToken string
 	zoneID   string
 	service  *cfDns.RecordService
+	tunnels  *tunnels
 }
 
-func New(apiToken, zoneID string) (cloudflareProvider, error) {
+func New(apiURL, apiToken, zoneID, accountID string) (cloudflareProvider, error) {
+	opts := options(apiURL, apiToken)
 	return cloudflareProvider{
 		apiToken: apiToken,
 		zoneID:   zoneID,
-		service:  cfDns.NewRecordService(option.WithEnvironmentProduction(), option.WithAPIToken(apiToken)),
+		service:  cfDns.NewRecordService(opts...),
+		tunnels: &tunnels{
+			accountID: accountID,
+			client:    cloudflare.NewClient(opts...),
+			ids:       map[string]string{},
+		},
 	}, nil
 }
 
-func FetchZoneID(apiToken string, domain string) (string, error) {
-	service := zones.NewZoneService(option.WithEnvironmentProduction(), option.WithAPIToken(apiToken))
-	zones := service.ListAutoPaging(context.Background(), zones.ZoneListParams{
+// options returns the request options of all API clients. Without apiURL, the production API is used
+func options(apiURL, apiToken string) []option.RequestOption {
+	opts := []option.RequestOption{option.WithEnvironmentProduction(), option.WithAPIToken(apiToken)}
+	if apiURL != "" {
+		opts = append(opts, option.WithBaseURL(apiURL))
+	}
+	return opts
+}
+
+func (cfp cloudflareProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func FetchZoneID(apiURL, apiToken, domain string) (string, error) {
+	zones, err := fetchZones(apiURL, apiToken, zones.ZoneListParams{
 		Name: cloudflare.F(domain),
 	})
-	for zones.Next() {
-		if zones.Err() != nil {
-			return "", zones.Err()
-		}
-		if zones.Current().Name == domain {
-			return zones.Current().ID, nil
-		}
+	if err != nil {
+		return "", err
+	}
+	if id, ok := zones[domain]; ok {
+		return id, nil
 	}
 	return "", fmt.Errorf("no zone found for domain %s", domain)
 }
 
+// FetchZones returns the IDs of all zones the token can access, keyed by zone name
+func FetchZones(apiURL, apiToken string) (map[string]string, error) {
+	return fetchZones(apiURL, apiToken, zones.ZoneListParams{})
+}
+
+func fetchZones(apiURL, apiToken string, params zones.ZoneListParams) (map[string]string, error) {
+	service := zones.NewZoneService(options(apiURL, apiToken)...)
+	results := service.ListAutoPaging(context.Background(), params)
+
+	zoneIDs := map[string]string{}
+	for results.Next() {
+		zoneIDs[results.Current().Name] = results.Current().ID
+	}
+	if results.Err() != nil {
+		return nil, results.Err()
+	}
+	return zoneIDs, nil
+}
+
 func (cfp cloudflareProvider) List() ([]dns.Record, error) {
 	ip4Records, err := cfp.list(constants.RecordTypeA)
 	if err != nil {
@@ -59,7 +102,17 @@ func (cfp cloudflareProvider) List() ([]dns.Record, error) {
 		return nil, err
 	}
 
-	return slices.Concat(ip4Records, ip6Records, cnameRecords), nil
+	txtRecords, err := cfp.list(constants.RecordTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	mxRecords, err := cfp.list(constants.RecordTypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Concat(ip4Records, ip6Records, cnameRecords, txtRecords, mxRecords), nil
 }
 
 func (cfp cloudflareProvider) list(recordType string) ([]dns.Record, error) {
@@ -102,12 +155,13 @@ func (cfp cloudflareProvider) Create(record dns.Record) (dns.Record, error) {
 	createdRecord, err := cfp.service.New(context.Background(), cfDns.RecordNewParams{
 		ZoneID: cloudflare.F(cfp.zoneID),
 		Body: cfDns.RecordNewParamsBody{
-			Name:    cloudflare.F(record.Name),
-			Type:    cloudflare.F(cfDns.RecordNewParamsBodyType(record.Type)),
-			Proxied: cloudflare.F(record.Proxied),
-			TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
-			Content: cloudflare.F(record.Content),
-			Comment: cloudflare.F(record.Comment),
+			Name:     cloudflare.F(record.Name),
+			Type:     cloudflare.F(cfDns.RecordNewParamsBodyType(record.Type)),
+			Proxied:  cloudflare.F(record.Proxied),
+			TTL:      cloudflare.F(cfDns.TTL(record.TTL)),
+			Content:  cloudflare.F(toContent(record)),
+			Comment:  cloudflare.F(record.Comment),
+			Priority: cloudflare.F(float64(record.Priority)),
 		},
 	})
 
@@ -121,12 +175,13 @@ func (cfp cloudflareProvider) Update(record dns.Record) (dns.Record, error) {
 	updatedRecord, err := cfp.service.Update(context.Background(), record.ID, cfDns.RecordUpdateParams{
 		ZoneID: cloudflare.F(cfp.zoneID),
 		Body: cfDns.RecordUpdateParamsBody{
-			Name:    cloudflare.F(record.Name),
-			Type:    cloudflare.F(cfDns.RecordUpdateParamsBodyType(record.Type)),
-			Proxied: cloudflare.F(record.Proxied),
-			TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
-			Content: cloudflare.F(record.Content),
-			Comment: cloudflare.F(record.Comment),
+			Name:     cloudflare.F(record.Name),
+			Type:     cloudflare.F(cfDns.RecordUpdateParamsBodyType(record.Type)),
+			Proxied:  cloudflare.F(record.Proxied),
+			TTL:      cloudflare.F(cfDns.TTL(record.TTL)),
+			Content:  cloudflare.F(toContent(record)),
+			Comment:  cloudflare.F(record.Comment),
+			Priority: cloudflare.F(float64(record.Priority)),
 		},
 	})
 
@@ -140,7 +195,124 @@ func (cfp cloudflareProvider) Delete(record dns.Record) error {
 	_, err := cfp.service.Delete(context.Background(), record.ID, cfDns.RecordDeleteParams{
 		ZoneID: cloudflare.F(cfp.zoneID),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	cfp.removeTunnelRoutes([]dns.Record{record})
+	return nil
+}
+
+// Apply sends all changes through the batch endpoint. Cloudflare executes a batch in a single transaction,
+// so either all changes are applied or none of them.
+func (cfp cloudflareProvider) Apply(changes []dns.Change) error {
+	var deleted []dns.Record
+	var deletes []cfDns.RecordBatchParamsDelete
+	var puts []cfDns.BatchPutUnionParam
+	var posts []cfDns.RecordBatchParamsPostUnion
+
+	for _, change := range changes {
+		record := change.Record
+		switch change.Action {
+		case dns.ActionCreate:
+			posts = append(posts, cfDns.RecordBatchParamsPost{
+				Name:     cloudflare.F(record.Name),
+				Type:     cloudflare.F(cfDns.RecordBatchParamsPostsType(record.Type)),
+				Proxied:  cloudflare.F(record.Proxied),
+				TTL:      cloudflare.F(cfDns.TTL(record.TTL)),
+				Content:  cloudflare.F(toContent(record)),
+				Comment:  cloudflare.F(record.Comment),
+				Priority: cloudflare.F(float64(record.Priority)),
+			})
+		case dns.ActionUpdate:
+			put, err := toBatchPut(record)
+			if err != nil {
+				return err
+			}
+			puts = append(puts, put)
+		case dns.ActionDelete:
+			deleted = append(deleted, record)
+			deletes = append(deletes, cfDns.RecordBatchParamsDelete{ID: cloudflare.F(record.ID)})
+		}
+	}
+
+	_, err := cfp.service.Batch(context.Background(), cfDns.RecordBatchParams{
+		ZoneID:  cloudflare.F(cfp.zoneID),
+		Deletes: cloudflare.F(deletes),
+		Puts:    cloudflare.F(puts),
+		Posts:   cloudflare.F(posts),
+	})
+	if err != nil {
+		return err
+	}
+	cfp.removeTunnelRoutes(deleted)
+	return nil
+}
+
+// toBatchPut returns the typed overwrite of an existing record, the batch endpoint has no generic variant
+func toBatchPut(record dns.Record) (cfDns.BatchPutUnionParam, error) {
+	switch record.Type {
+	case constants.RecordTypeA:
+		return cfDns.BatchPutARecordParam{
+			ID: cloudflare.F(record.ID),
+			ARecordParam: cfDns.ARecordParam{
+				Name:    cloudflare.F(record.Name),
+				Type:    cloudflare.F(cfDns.ARecordTypeA),
+				Proxied: cloudflare.F(record.Proxied),
+				TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
+				Content: cloudflare.F(toContent(record)),
+				Comment: cloudflare.F(record.Comment),
+			},
+		}, nil
+	case constants.RecordTypeAAAA:
+		return cfDns.BatchPutAAAARecordParam{
+			ID: cloudflare.F(record.ID),
+			AAAARecordParam: cfDns.AAAARecordParam{
+				Name:    cloudflare.F(record.Name),
+				Type:    cloudflare.F(cfDns.AAAARecordTypeAAAA),
+				Proxied: cloudflare.F(record.Proxied),
+				TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
+				Content: cloudflare.F(toContent(record)),
+				Comment: cloudflare.F(record.Comment),
+			},
+		}, nil
+	case constants.RecordTypeCNAME:
+		return cfDns.BatchPutCNAMERecordParam{
+			ID: cloudflare.F(record.ID),
+			CNAMERecordParam: cfDns.CNAMERecordParam{
+				Name:    cloudflare.F(record.Name),
+				Type:    cloudflare.F(cfDns.CNAMERecordTypeCNAME),
+				Proxied: cloudflare.F(record.Proxied),
+				TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
+				Content: cloudflare.F(toContent(record)),
+				Comment: cloudflare.F(record.Comment),
+			},
+		}, nil
+	case constants.RecordTypeTXT:
+		return cfDns.BatchPutTXTRecordParam{
+			ID: cloudflare.F(record.ID),
+			TXTRecordParam: cfDns.TXTRecordParam{
+				Name:    cloudflare.F(record.Name),
+				Type:    cloudflare.F(cfDns.TXTRecordTypeTXT),
+				Proxied: cloudflare.F(record.Proxied),
+				TTL:     cloudflare.F(cfDns.TTL(record.TTL)),
+				Content: cloudflare.F(toContent(record)),
+				Comment: cloudflare.F(record.Comment),
+			},
+		}, nil
+	case constants.RecordTypeMX:
+		return cfDns.BatchPutMXRecordParam{
+			ID: cloudflare.F(record.ID),
+			MXRecordParam: cfDns.MXRecordParam{
+				Name:     cloudflare.F(record.Name),
+				Type:     cloudflare.F(cfDns.MXRecordTypeMX),
+				TTL:      cloudflare.F(cfDns.TTL(record.TTL)),
+				Content:  cloudflare.F(toContent(record)),
+				Comment:  cloudflare.F(record.Comment),
+				Priority: cloudflare.F(float64(record.Priority)),
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("record type %s of %s cannot be updated in a batch", record.Type, record.Name)
 }
 
 func mapRecords(records []cfDns.RecordResponse) []dns.Record {
@@ -153,14 +325,28 @@ func mapRecords(records []cfDns.RecordResponse) []dns.Record {
 	return mappedRecords
 }
 
+// TXT contents are sent quoted, so values longer than 255 characters are split into several strings
+func toContent(record dns.Record) string {
+	if record.Type == constants.RecordTypeTXT {
+		return dns.QuoteTXT(record.Content)
+	}
+	return record.Content
+}
+
 func mapRecord(r cfDns.RecordResponse) dns.Record {
+	content := r.Content
+	if r.Type == cfDns.RecordResponseTypeTXT {
+		content = dns.UnquoteTXT(content)
+	}
+
 	return dns.Record{
-		ID:      r.ID,
-		Name:    r.Name,
-		Type:    string(r.Type),
-		Content: r.Content,
-		Proxied: r.Proxied,
-		TTL:     int(r.TTL),
-		Comment: r.Comment,
+		ID:       r.ID,
+		Name:     r.Name,
+		Type:     string(r.Type),
+		Content:  content,
+		Proxied:  r.Proxied,
+		TTL:      int(r.TTL),
+		Comment:  r.Comment,
+		Priority: int(r.Priority),
 	}
 }
@@ -0,0 +1,207 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const (
+	zoneID    = "zone-1"
+	accountID = "account-1"
+)
+
+// fakeCloudflare serves the endpoints of the Cloudflare API used by dockdns and records the batch requests
+type fakeCloudflare struct {
+	// Zones of the account as id and name, listed in pages of two zones
+	zones   []map[string]any
+	batches []batchRequest
+	fail    bool
+
+	// Tunnel IDs by name and the configuration of each tunnel
+	tunnels       map[string]string
+	configs       map[string]map[string]any
+	tunnelLookups int
+	configPuts    int
+}
+
+type batchRequest struct {
+	Deletes []map[string]any `json:"deletes"`
+	Puts    []map[string]any `json:"puts"`
+	Posts   []map[string]any `json:"posts"`
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/client/v4")
+	switch {
+	case r.Method == http.MethodGet && path == "/zones":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		matching := []any{}
+		for _, zone := range f.zones {
+			if name := r.URL.Query().Get("name"); name == "" || zone["name"] == name {
+				matching = append(matching, zone)
+			}
+		}
+		writeResult(w, matching[min((page-1)*2, len(matching)):min(page*2, len(matching))])
+	case r.Method == http.MethodPost && path == "/zones/"+zoneID+"/dns_records/batch":
+		if f.fail {
+			writeError(w, http.StatusBadRequest, "invalid record")
+			return
+		}
+		var body batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.batches = append(f.batches, body)
+		writeResult(w, map[string]any{})
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/zones/"+zoneID+"/dns_records/"):
+		writeResult(w, map[string]any{"id": strings.TrimPrefix(path, "/zones/"+zoneID+"/dns_records/")})
+	case r.Method == http.MethodGet && path == "/accounts/"+accountID+"/cfd_tunnel":
+		f.tunnelLookups++
+		result := []any{}
+		name := r.URL.Query().Get("name")
+		if id, ok := f.tunnels[name]; ok && r.URL.Query().Get("page") == "" {
+			result = append(result, map[string]any{"id": id, "name": name})
+		}
+		writeResult(w, result)
+	case strings.HasPrefix(path, "/accounts/"+accountID+"/cfd_tunnel/") && strings.HasSuffix(path, "/configurations"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/accounts/"+accountID+"/cfd_tunnel/"), "/configurations")
+		switch r.Method {
+		case http.MethodGet:
+			writeResult(w, map[string]any{"tunnel_id": id, "config": f.configs[id]})
+		case http.MethodPut:
+			var body tunnelConfiguration
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			f.configPuts++
+			f.configs[id] = body.Config
+			writeResult(w, body)
+		}
+	default:
+		writeError(w, http.StatusNotFound, r.Method+" "+path+" not found")
+	}
+}
+
+// writeResult writes the response envelope of the Cloudflare API
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "messages": []any{}, "result": result})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []any{map[string]any{"code": 1000, "message": message}}, "messages": []any{}, "result": nil})
+}
+
+func newTestServer(t *testing.T, handler http.Handler) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL + "/client/v4"
+}
+
+func newTestProvider(t *testing.T, handler http.Handler, accountID string) cloudflareProvider {
+	p, err := New(newTestServer(t, handler), "token", zoneID, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeCloudflare{}
+	p := newTestProvider(t, fake, "")
+
+	err := p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 300, Priority: 10}},
+		{Action: dns.ActionUpdate, Record: dns.Record{ID: "a-1", Name: "www.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 1, Proxied: true, Comment: "dockdns"}},
+		{Action: dns.ActionDelete, Record: dns.Record{ID: "cname-1", Name: "old.example.com", Type: constants.RecordTypeCNAME, Content: "example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := []batchRequest{{
+		Deletes: []map[string]any{{"id": "cname-1"}},
+		Puts: []map[string]any{
+			{"id": "a-1", "name": "www.example.com", "type": "A", "content": "10.0.0.2", "ttl": 1.0, "proxied": true, "comment": "dockdns"},
+		},
+		Posts: []map[string]any{
+			{"name": "example.com", "type": "TXT", "content": `"v=spf1 -all"`, "ttl": 300.0, "proxied": false, "comment": "", "priority": 0.0},
+			{"name": "example.com", "type": "MX", "content": "mx.example.com", "ttl": 300.0, "proxied": false, "comment": "", "priority": 10.0},
+		},
+	}}
+	if !reflect.DeepEqual(fake.batches, want) {
+		t.Errorf("sent %+v, want %+v", fake.batches, want)
+	}
+}
+
+func TestApplyReturnsAPIErrors(t *testing.T) {
+	fake := &fakeCloudflare{fail: true}
+	p := newTestProvider(t, fake, "")
+
+	err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid record") {
+		t.Errorf("Apply() error = %v, want the API error", err)
+	}
+}
+
+func TestApplyRejectsUnsupportedUpdates(t *testing.T) {
+	fake := &fakeCloudflare{}
+	p := newTestProvider(t, fake, "")
+
+	err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: dns.Record{ID: "srv-1", Name: "example.com", Type: "SRV", Content: "0 5 5060 sip.example.com"}}})
+	if err == nil {
+		t.Error("Apply() accepted an update of an SRV record")
+	}
+	if len(fake.batches) != 0 {
+		t.Errorf("sent %+v, want no batch", fake.batches)
+	}
+}
+
+func TestFetchZones(t *testing.T) {
+	fake := &fakeCloudflare{zones: []map[string]any{
+		{"id": "zone-1", "name": "example.com"},
+		{"id": "zone-2", "name": "example.org"},
+		{"id": "zone-3", "name": "example.net"},
+	}}
+	apiURL := newTestServer(t, fake)
+
+	// All pages are listed
+	zoneIDs, err := FetchZones(apiURL, "token")
+	if err != nil {
+		t.Fatalf("FetchZones() error = %v", err)
+	}
+	want := map[string]string{"example.com": "zone-1", "example.org": "zone-2", "example.net": "zone-3"}
+	if !reflect.DeepEqual(zoneIDs, want) {
+		t.Errorf("FetchZones() = %v, want %v", zoneIDs, want)
+	}
+
+	id, err := FetchZoneID(apiURL, "token", "example.net")
+	if err != nil || id != "zone-3" {
+		t.Errorf("FetchZoneID() = %s, %v, want zone-3", id, err)
+	}
+	if _, err := FetchZoneID(apiURL, "token", "unknown.com"); err == nil {
+		t.Error("FetchZoneID() succeeded for an unknown zone")
+	}
+	if _, err := FetchZones(apiURL, "wrong"); err == nil {
+		t.Error("FetchZones() succeeded with an invalid token")
+	}
+}
@@ -0,0 +1,181 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/cloudflare/cloudflare-go/v7"
+	"github.com/cloudflare/cloudflare-go/v7/zero_trust"
+)
+
+const tunnelDomain = ".cfargotunnel.com"
+
+// Catch-all rule added to new configurations, the last ingress rule must not have a hostname
+const defaultIngressService = "http_status:404"
+
+// tunnels manages the remotely managed configuration of the Cloudflare Tunnels of an account
+type tunnels struct {
+	accountID string
+	client    *cloudflare.Client
+
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// The configuration is read and written as is, so settings not managed by dockdns are kept
+type tunnelConfiguration struct {
+	Config map[string]any `json:"config"`
+}
+
+func (cfp cloudflareProvider) TunnelTarget(tunnel string) (string, error) {
+	id, err := cfp.tunnels.id(tunnel)
+	if err != nil {
+		return "", err
+	}
+	return id + tunnelDomain, nil
+}
+
+// RouteIngress adds the ingress rules of the hostnames to the tunnel configuration, or updates their service.
+// Rules of managed hostnames routed elsewhere are removed, rules of other hostnames are left alone.
+func (cfp cloudflareProvider) RouteIngress(tunnel string, services map[string]string, managed []string) error {
+	id, err := cfp.tunnels.id(tunnel)
+	if err != nil {
+		return err
+	}
+
+	return cfp.tunnels.updateIngress(id, func(ingress []map[string]any) []map[string]any {
+		ingress = slices.DeleteFunc(ingress, func(rule map[string]any) bool {
+			return slices.ContainsFunc(managed, func(hostname string) bool {
+				_, routed := services[hostname]
+				return !routed && matchesHostname(rule, hostname)
+			})
+		})
+		for _, hostname := range slices.Sorted(maps.Keys(services)) {
+			idx := slices.IndexFunc(ingress, func(rule map[string]any) bool { return matchesHostname(rule, hostname) })
+			if idx >= 0 {
+				ingress[idx]["service"] = services[hostname]
+				continue
+			}
+			// New rules go before the catch-all rule
+			rule := map[string]any{"hostname": hostname, "service": services[hostname]}
+			ingress = slices.Insert(ingress, max(len(ingress)-1, 0), rule)
+		}
+		return ingress
+	})
+}
+
+// removeTunnelRoutes removes the ingress rules of deleted CNAMEs that pointed to a tunnel, e.g. when a purge
+// removes a hostname tunneled before a restart. The records are already gone at this point, so failures are only logged.
+func (cfp cloudflareProvider) removeTunnelRoutes(records []dns.Record) {
+	hostnames := map[string][]string{}
+	for _, record := range records {
+		if id, isTunnel := strings.CutSuffix(record.Content, tunnelDomain); isTunnel && record.Type == constants.RecordTypeCNAME {
+			hostnames[id] = append(hostnames[id], record.Name)
+		}
+	}
+
+	for id, names := range hostnames {
+		if cfp.tunnels.accountID == "" {
+			slog.Warn("no account id set, cannot remove tunnel ingress rules", "tunnel", id, "hostnames", names)
+			continue
+		}
+		err := cfp.tunnels.updateIngress(id, func(ingress []map[string]any) []map[string]any {
+			return slices.DeleteFunc(ingress, func(rule map[string]any) bool {
+				return slices.ContainsFunc(names, func(name string) bool { return matchesHostname(rule, name) })
+			})
+		})
+		if err != nil {
+			slog.Error("failed to remove tunnel ingress rules", "tunnel", id, "hostnames", names, "error", err)
+		}
+	}
+}
+
+// id returns the ID of the tunnel with the given name
+func (t *tunnels) id(name string) (string, error) {
+	if t.accountID == "" {
+		return "", errors.New("no account id set, required for tunnels")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[name]; ok {
+		return id, nil
+	}
+
+	service := zero_trust.NewTunnelCloudflaredService(t.client.Options...)
+	results := service.ListAutoPaging(context.Background(), zero_trust.TunnelCloudflaredListParams{
+		AccountID: cloudflare.F(t.accountID),
+		Name:      cloudflare.F(name),
+		IsDeleted: cloudflare.F(false),
+	})
+	for results.Next() {
+		if results.Current().Name == name {
+			t.ids[name] = results.Current().ID
+			return results.Current().ID, nil
+		}
+	}
+	if results.Err() != nil {
+		return "", results.Err()
+	}
+	return "", fmt.Errorf("no tunnel found with name %s", name)
+}
+
+// updateIngress applies update to the ingress rules of the tunnel and saves the configuration if they changed
+func (t *tunnels) updateIngress(id string, update func(ingress []map[string]any) []map[string]any) error {
+	path := fmt.Sprintf("accounts/%s/cfd_tunnel/%s/configurations", t.accountID, id)
+
+	var current struct {
+		Result tunnelConfiguration `json:"result"`
+	}
+	if err := t.client.Get(context.Background(), path, nil, &current); err != nil {
+		return fmt.Errorf("could not read configuration of tunnel %s: %w", id, err)
+	}
+
+	config := current.Result.Config
+	if config == nil {
+		config = map[string]any{}
+	}
+	ingress := ingressRules(config)
+	if len(ingress) == 0 {
+		ingress = []map[string]any{{"service": defaultIngressService}}
+	}
+	before := fmt.Sprint(ingress)
+
+	ingress = update(ingress)
+	if fmt.Sprint(ingress) == before {
+		return nil
+	}
+	config["ingress"] = ingress
+
+	if err := t.client.Put(context.Background(), path, tunnelConfiguration{Config: config}, nil); err != nil {
+		return fmt.Errorf("could not update configuration of tunnel %s: %w", id, err)
+	}
+	return nil
+}
+
+func ingressRules(config map[string]any) []map[string]any {
+	rules, _ := config["ingress"].([]any)
+
+	var ingress []map[string]any
+	for _, rule := range rules {
+		if r, ok := rule.(map[string]any); ok {
+			ingress = append(ingress, r)
+		}
+	}
+	return ingress
+}
+
+// Only rules for the whole hostname are managed, rules limited to a path are left alone
+func matchesHostname(rule map[string]any, hostname string) bool {
+	ruleHostname, _ := rule["hostname"].(string)
+	path, _ := rule["path"].(string)
+	return strings.EqualFold(ruleHostname, hostname) && path == ""
+}
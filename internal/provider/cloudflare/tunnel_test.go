@@ -0,0 +1,163 @@
+package cloudflare
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const tunnelID = "tunnel-1"
+
+// ingress returns the ingress rules as decoded from JSON
+func ingress(rules ...map[string]any) []any {
+	result := []any{}
+	for _, rule := range rules {
+		result = append(result, rule)
+	}
+	return result
+}
+
+func rule(hostname, service string) map[string]any {
+	if hostname == "" {
+		return map[string]any{"service": service}
+	}
+	return map[string]any{"hostname": hostname, "service": service}
+}
+
+func newTunnelProvider(t *testing.T, config map[string]any) (cloudflareProvider, *fakeCloudflare) {
+	fake := &fakeCloudflare{
+		tunnels: map[string]string{"home": tunnelID},
+		configs: map[string]map[string]any{tunnelID: config},
+	}
+	return newTestProvider(t, fake, accountID), fake
+}
+
+func TestRouteIngressInsertsBeforeCatchAll(t *testing.T) {
+	p, fake := newTunnelProvider(t, map[string]any{
+		"ingress":       ingress(rule("other.example.com", "http://other:80"), rule("", "http_status:404")),
+		"originRequest": map[string]any{"noTLSVerify": true},
+	})
+
+	target, err := p.TunnelTarget("home")
+	if err != nil {
+		t.Fatalf("TunnelTarget() error = %v", err)
+	}
+	if target != tunnelID+tunnelDomain {
+		t.Errorf("TunnelTarget() = %s, want %s", target, tunnelID+tunnelDomain)
+	}
+
+	if err := p.RouteIngress("home", map[string]string{"app.example.com": "http://app:80"}, []string{"app.example.com"}); err != nil {
+		t.Fatalf("RouteIngress() error = %v", err)
+	}
+
+	want := map[string]any{
+		"ingress":       ingress(rule("other.example.com", "http://other:80"), rule("app.example.com", "http://app:80"), rule("", "http_status:404")),
+		"originRequest": map[string]any{"noTLSVerify": true},
+	}
+	if !reflect.DeepEqual(fake.configs[tunnelID], want) {
+		t.Errorf("config = %v, want %v", fake.configs[tunnelID], want)
+	}
+	// The tunnel ID is looked up once
+	if fake.tunnelLookups != 1 {
+		t.Errorf("looked up the tunnel %d times, want 1", fake.tunnelLookups)
+	}
+}
+
+func TestRouteIngressAddsCatchAllToNewConfiguration(t *testing.T) {
+	p, fake := newTunnelProvider(t, nil)
+
+	if err := p.RouteIngress("home", map[string]string{"app.example.com": "http://app:80"}, []string{"app.example.com"}); err != nil {
+		t.Fatalf("RouteIngress() error = %v", err)
+	}
+	want := map[string]any{"ingress": ingress(rule("app.example.com", "http://app:80"), rule("", defaultIngressService))}
+	if !reflect.DeepEqual(fake.configs[tunnelID], want) {
+		t.Errorf("config = %v, want %v", fake.configs[tunnelID], want)
+	}
+}
+
+func TestRouteIngressUpdatesService(t *testing.T) {
+	p, fake := newTunnelProvider(t, map[string]any{
+		"ingress": ingress(rule("APP.example.com", "http://app:80"), rule("", "http_status:404")),
+	})
+
+	if err := p.RouteIngress("home", map[string]string{"app.example.com": "http://app:8080"}, []string{"app.example.com"}); err != nil {
+		t.Fatalf("RouteIngress() error = %v", err)
+	}
+	want := map[string]any{"ingress": ingress(rule("APP.example.com", "http://app:8080"), rule("", "http_status:404"))}
+	if !reflect.DeepEqual(fake.configs[tunnelID], want) {
+		t.Errorf("config = %v, want %v", fake.configs[tunnelID], want)
+	}
+}
+
+func TestRouteIngressRemovesOnlyManagedHostnames(t *testing.T) {
+	pathRule := map[string]any{"hostname": "old.example.com", "path": "/api", "service": "http://api:80"}
+	p, fake := newTunnelProvider(t, map[string]any{
+		"ingress": ingress(
+			rule("old.example.com", "http://old:80"),
+			pathRule,
+			rule("foreign.example.com", "http://foreign:80"),
+			rule("app.example.com", "http://app:80"),
+			rule("", "http_status:404"),
+		),
+	})
+
+	err := p.RouteIngress("home", map[string]string{"app.example.com": "http://app:80"}, []string{"app.example.com", "old.example.com"})
+	if err != nil {
+		t.Fatalf("RouteIngress() error = %v", err)
+	}
+	want := map[string]any{"ingress": ingress(pathRule, rule("foreign.example.com", "http://foreign:80"), rule("app.example.com", "http://app:80"), rule("", "http_status:404"))}
+	if !reflect.DeepEqual(fake.configs[tunnelID], want) {
+		t.Errorf("config = %v, want %v", fake.configs[tunnelID], want)
+	}
+}
+
+func TestRouteIngressWithoutChangesDoesNotWrite(t *testing.T) {
+	p, fake := newTunnelProvider(t, map[string]any{
+		"ingress": ingress(rule("app.example.com", "http://app:80"), rule("", "http_status:404")),
+	})
+
+	if err := p.RouteIngress("home", map[string]string{"app.example.com": "http://app:80"}, []string{"app.example.com"}); err != nil {
+		t.Fatalf("RouteIngress() error = %v", err)
+	}
+	if fake.configPuts != 0 {
+		t.Errorf("wrote the configuration %d times, want no write", fake.configPuts)
+	}
+}
+
+func TestRouteIngressUnknownTunnel(t *testing.T) {
+	p, fake := newTunnelProvider(t, nil)
+
+	if err := p.RouteIngress("office", map[string]string{"app.example.com": "http://app:80"}, []string{"app.example.com"}); err == nil {
+		t.Error("RouteIngress() succeeded for an unknown tunnel")
+	}
+	if fake.configPuts != 0 {
+		t.Errorf("wrote the configuration %d times, want no write", fake.configPuts)
+	}
+}
+
+func TestDeleteRemovesTunnelRoutes(t *testing.T) {
+	pathRule := map[string]any{"hostname": "app.example.com", "path": "/api", "service": "http://api:80"}
+	p, fake := newTunnelProvider(t, map[string]any{
+		"ingress": ingress(rule("app.example.com", "http://app:80"), pathRule, rule("other.example.com", "http://other:80"), rule("", "http_status:404")),
+	})
+
+	err := p.Delete(dns.Record{ID: "cname-1", Name: "app.example.com", Type: constants.RecordTypeCNAME, Content: tunnelID + tunnelDomain})
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want := map[string]any{"ingress": ingress(pathRule, rule("other.example.com", "http://other:80"), rule("", "http_status:404"))}
+	if !reflect.DeepEqual(fake.configs[tunnelID], want) {
+		t.Errorf("config = %v, want %v", fake.configs[tunnelID], want)
+	}
+
+	// Records not pointing to a tunnel leave the configuration alone
+	err = p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: dns.Record{ID: "cname-2", Name: "other.example.com", Type: constants.RecordTypeCNAME, Content: "example.com"}}})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if fake.configPuts != 1 {
+		t.Errorf("wrote the configuration %d times, want 1", fake.configPuts)
+	}
+}
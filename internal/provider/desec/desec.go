@@ -0,0 +1,317 @@
+package desec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const (
+	defaultApiURL     = "https://desec.io/api/v1"
+	maxRetries        = 5
+	defaultRetryAfter = 5 * time.Second
+)
+
+var errNotFound = errors.New("not found")
+
+// capabilities of all domains, the minimum TTL is read from the domain in New
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type desecProvider struct {
+	apiURL       string
+	apiToken     string
+	zone         string
+	client       *http.Client
+	capabilities dns.Capabilities
+}
+
+type domain struct {
+	MinimumTTL int `json:"minimum_ttl"`
+}
+
+type rrset struct {
+	Subname string   `json:"subname"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+func New(apiURL, apiToken, zone string) (desecProvider, error) {
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+
+	p := desecProvider{
+		apiURL:       strings.TrimSuffix(apiURL, "/"),
+		apiToken:     apiToken,
+		zone:         zone,
+		client:       jsonapi.NewHTTPClient(),
+		capabilities: capabilities,
+	}
+
+	// deSEC rejects RRsets with a TTL below the minimum of the domain, which depends on the account
+	var d domain
+	if _, err := p.do(http.MethodGet, "/domains/"+url.PathEscape(zone)+"/", nil, &d); err != nil {
+		return desecProvider{}, fmt.Errorf("failed to read the minimum TTL of deSEC domain %s: %w", zone, err)
+	}
+	p.capabilities.MinTTL = d.MinimumTTL
+	return p, nil
+}
+
+func (p desecProvider) Capabilities() dns.Capabilities {
+	return p.capabilities
+}
+
+func (p desecProvider) List() ([]dns.Record, error) {
+	rrsets, err := p.rrsets()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, set := range rrsets {
+		if !capabilities.Supports(set.Type) {
+			continue
+		}
+		records = append(records, p.mapRecords(set)...)
+	}
+	return records, nil
+}
+
+func (p desecProvider) Get(domain, recordType string) (dns.Record, error) {
+	set, err := p.rrset(domain, recordType)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	records := p.mapRecords(set)
+	if len(records) == 0 {
+		return dns.Record{}, nil
+	}
+	return records[0], nil
+}
+
+func (p desecProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p desecProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p desecProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply groups all changes into RRsets and sends them in one bulk request, which deSEC applies atomically.
+// The existing RRsets are listed once, so a run takes two requests and stays within deSEC's strict rate limits.
+func (p desecProvider) Apply(changes []dns.Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	rrsets, err := p.rrsets()
+	if err != nil {
+		return err
+	}
+	existing := map[string]rrset{}
+	for _, set := range rrsets {
+		existing[dns.RecordID(set.Subname, set.Type)] = set
+	}
+
+	fetch := func(name, recordType string) (*dns.RRSet, error) {
+		set, ok := existing[dns.RecordID(p.subname(name), recordType)]
+		if !ok || len(set.Records) == 0 {
+			return nil, nil
+		}
+		return &dns.RRSet{Name: name, Type: recordType, TTL: set.TTL, Values: set.Records}, nil
+	}
+
+	sets, err := dns.MergeRRSets(changes, fetch, dns.PresentationValue)
+	if err != nil {
+		return err
+	}
+
+	var body []rrset
+	for _, set := range sets {
+		desired := set.Desired
+		// An RRset without records is deleted
+		records := desired.Values
+		if records == nil {
+			records = []string{}
+		}
+		body = append(body, rrset{Subname: p.subname(desired.Name), Type: desired.Type, TTL: desired.TTL, Records: records})
+	}
+	if len(body) == 0 {
+		return nil
+	}
+
+	_, err = p.do(http.MethodPatch, "/domains/"+url.PathEscape(p.zone)+"/rrsets/", body, nil)
+	return err
+}
+
+// rrsets returns all RRsets of the domain, following the pagination of large domains
+func (p desecProvider) rrsets() ([]rrset, error) {
+	var rrsets []rrset
+
+	path := "/domains/" + url.PathEscape(p.zone) + "/rrsets/"
+	for path != "" {
+		var page []rrset
+		next, err := p.do(http.MethodGet, path, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		rrsets = append(rrsets, page...)
+		path = next
+	}
+	return rrsets, nil
+}
+
+// rrset returns the RRset for the given name and type. If it does not exist, an empty RRset is returned
+func (p desecProvider) rrset(domain, recordType string) (rrset, error) {
+	subname := p.subname(domain)
+	set := rrset{Subname: subname, Type: recordType, Records: []string{}}
+
+	urlSubname := subname
+	if urlSubname == "" {
+		urlSubname = "@"
+	}
+
+	path := fmt.Sprintf("/domains/%s/rrsets/%s/%s/", url.PathEscape(p.zone), url.PathEscape(urlSubname), url.PathEscape(recordType))
+	_, err := p.do(http.MethodGet, path, nil, &set)
+	if errors.Is(err, errNotFound) {
+		return set, nil
+	}
+	return set, err
+}
+
+// do sends a request to the deSEC API. Rate limited requests are retried after the period requested by the API.
+// It returns the path of the next page, if the response is paginated.
+func (p desecProvider) do(method, path string, body, result any) (string, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, p.apiURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Token "+p.apiToken)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			resp.Body.Close()
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			slog.Debug("deSEC rate limit reached, retrying", "path", path, "retryAfter", wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return "", errNotFound
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			msg, _ := io.ReadAll(resp.Body)
+			return "", fmt.Errorf("deSEC api returned %s for zone %s: %s", resp.Status, p.zone, strings.TrimSpace(string(msg)))
+		}
+
+		if result != nil && resp.StatusCode != http.StatusNoContent {
+			if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+				return "", err
+			}
+		}
+		return p.nextPage(resp.Header.Get("Link")), nil
+	}
+}
+
+// nextPage extracts the path of the next page from a Link header, e.g. '<https://desec.io/api/v1/...?cursor=abc>; rel="next"'
+func (p desecProvider) nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, rel, found := strings.Cut(part, ";")
+		if !found || !strings.Contains(rel, `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		return strings.TrimPrefix(target, p.apiURL)
+	}
+	return ""
+}
+
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRetryAfter
+}
+
+func (p desecProvider) subname(domain string) string {
+	return dns.RelativeName(domain, p.zone)
+}
+
+func (p desecProvider) mapRecords(set rrset) []dns.Record {
+	var records []dns.Record
+
+	name := p.zone
+	if set.Subname != "" {
+		name = set.Subname + "." + p.zone
+	}
+
+	for _, value := range set.Records {
+		var priority int
+		switch set.Type {
+		case constants.RecordTypeCNAME:
+			value = strings.TrimSuffix(value, ".")
+		case constants.RecordTypeTXT:
+			value = dns.UnquoteTXT(value)
+		case constants.RecordTypeMX:
+			priority, value = dns.ParseMX(value)
+		}
+		records = append(records, dns.Record{
+			ID:       dns.RecordID(name, set.Type),
+			Name:     name,
+			Type:     set.Type,
+			Content:  value,
+			TTL:      set.TTL,
+			Priority: priority,
+		})
+	}
+	return records
+}
@@ -0,0 +1,126 @@
+package desec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeDeSEC serves the domain example.com with its RRsets and records the bulk requests
+type fakeDeSEC struct {
+	minimumTTL int
+	rrsets     []rrset
+	gets       []string
+	patches    [][]rrset
+}
+
+func (f *fakeDeSEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Token secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		f.gets = append(f.gets, r.URL.Path)
+		switch r.URL.Path {
+		case "/domains/example.com/":
+			json.NewEncoder(w).Encode(map[string]any{"name": "example.com", "minimum_ttl": f.minimumTTL})
+		case "/domains/example.com/rrsets/":
+			json.NewEncoder(w).Encode(f.rrsets)
+		default:
+			for _, set := range f.rrsets {
+				subname := set.Subname
+				if subname == "" {
+					subname = "@"
+				}
+				if r.URL.Path == "/domains/example.com/rrsets/"+subname+"/"+set.Type+"/" {
+					json.NewEncoder(w).Encode(set)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}
+	case http.MethodPatch:
+		var body []rrset
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.patches = append(f.patches, body)
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeDeSEC) desecProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "secret", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.gets = nil
+	return p
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeDeSEC{minimumTTL: 3600, rrsets: []rrset{
+		{Subname: "", Type: "TXT", TTL: 3600, Records: []string{`"v=spf1 -all"`, `"token=old"`}},
+		{Subname: "old", Type: "A", TTL: 3600, Records: []string{"10.0.0.1"}},
+		{Subname: "www", Type: "A", TTL: 3600, Records: []string{"10.0.0.2"}},
+		{Subname: "mail", Type: "MX", TTL: 3600, Records: []string{"10 mx.example.com."}},
+	}}
+	p := newTestProvider(t, fake)
+
+	err := p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 3600}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=old", TTL: 3600}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "old.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}},
+		// Unchanged RRsets are not sent
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "www.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 3600}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "new.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 7200}},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := [][]rrset{{
+		{Subname: "", Type: "TXT", TTL: 3600, Records: []string{`"v=spf1 -all"`, `"token=new"`}},
+		{Subname: "old", Type: "A", TTL: 3600, Records: []string{}},
+		{Subname: "new", Type: "CNAME", TTL: 7200, Records: []string{"example.com."}},
+	}}
+	if !reflect.DeepEqual(fake.patches, want) {
+		t.Errorf("sent %+v, want %+v", fake.patches, want)
+	}
+	// The existing RRsets are listed once instead of fetching every RRset on its own
+	if wantGets := []string{"/domains/example.com/rrsets/"}; !reflect.DeepEqual(fake.gets, wantGets) {
+		t.Errorf("requested %v, want %v", fake.gets, wantGets)
+	}
+}
+
+func TestMinimumTTLOfDomain(t *testing.T) {
+	fake := &fakeDeSEC{minimumTTL: 60}
+	p := newTestProvider(t, fake)
+
+	if got := p.Capabilities().MinTTL; got != 60 {
+		t.Errorf("Capabilities().MinTTL = %d, want 60", got)
+	}
+	if err := p.Capabilities().Validate(dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 60}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestNewFailsWithoutDomain(t *testing.T) {
+	server := httptest.NewServer(&fakeDeSEC{})
+	t.Cleanup(server.Close)
+
+	if _, err := New(server.URL, "secret", "unknown.com"); err == nil {
+		t.Error("New() succeeded for an unknown domain")
+	}
+}
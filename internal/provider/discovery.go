@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Tarow/dockdns/internal/config"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/cloudflare"
+)
+
+// accountZones discovers every zone the API token of a Cloudflare entry without name can access.
+// Zones are listed again on every run, so zones added to the account are picked up without a restart.
+type accountZones struct {
+	zoneCfg config.Zone
+	dryRun  bool
+
+	mu    sync.Mutex
+	zones map[string]discoveredZone
+}
+
+type discoveredZone struct {
+	id       string
+	backends []dns.Backend
+}
+
+// NewZoneSource creates the zone discovery of a zone entry without name
+func NewZoneSource(zoneCfg *config.Zone, dryRun bool) (dns.ZoneSource, error) {
+	if zoneCfg.Provider != Cloudflare {
+		return nil, fmt.Errorf("zone name is required for provider %s, only %s can discover zones", zoneCfg.Provider, Cloudflare)
+	}
+	if zoneCfg.ZoneID != "" {
+		return nil, fmt.Errorf("zone id %s set without zone name", zoneCfg.ZoneID)
+	}
+
+	return &accountZones{
+		zoneCfg: *zoneCfg,
+		dryRun:  dryRun,
+		zones:   map[string]discoveredZone{},
+	}, nil
+}
+
+func (a *accountZones) Zones() (map[string][]dns.Backend, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	zoneIDs, err := cloudflare.FetchZones(a.zoneCfg.ApiURL, a.zoneCfg.ApiToken)
+	if err != nil {
+		return a.backends(), fmt.Errorf("could not list cloudflare zones: %w", err)
+	}
+
+	var errs []error
+	discovered := map[string]discoveredZone{}
+	for name, id := range zoneIDs {
+		// Providers are reused as long as the zone exists, so they keep their caches between runs
+		if zone, known := a.zones[name]; known && zone.id == id {
+			discovered[name] = zone
+			continue
+		}
+
+		zoneCfg := a.zoneCfg
+		zoneCfg.Name = name
+		zoneCfg.ZoneID = id
+		backends, err := GetBackends(&zoneCfg, a.dryRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not create provider for zone %s: %w", name, err))
+			continue
+		}
+		discovered[name] = discoveredZone{id: id, backends: backends}
+	}
+	a.zones = discovered
+
+	return a.backends(), errors.Join(errs...)
+}
+
+func (a *accountZones) backends() map[string][]dns.Backend {
+	backends := map[string][]dns.Backend{}
+	for name, zone := range a.zones {
+		backends[name] = zone.backends
+	}
+	return backends
+}
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/config"
+)
+
+// newZoneServer serves the zones as zone list of the Cloudflare API, a nil map fails the request
+func newZoneServer(t *testing.T, zones *map[string]string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if *zones == nil {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []any{map[string]any{"code": 9109, "message": "unauthorized"}}})
+			return
+		}
+
+		result := []any{}
+		if r.URL.Query().Get("page") == "" {
+			for name, id := range *zones {
+				result = append(result, map[string]any{"id": id, "name": name})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "messages": []any{}, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/client/v4"
+}
+
+func TestAccountZones(t *testing.T) {
+	zones := map[string]string{"example.com": "zone-1", "example.org": "zone-2"}
+	source, err := NewZoneSource(&config.Zone{Provider: Cloudflare, ApiURL: newZoneServer(t, &zones), ApiToken: "token"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := source.Zones()
+	if err != nil {
+		t.Fatalf("Zones() error = %v", err)
+	}
+	if names := slices.Sorted(maps.Keys(first)); !slices.Equal(names, []string{"example.com", "example.org"}) {
+		t.Fatalf("Zones() = %v, want example.com and example.org", names)
+	}
+
+	// Removed zones are dropped, added zones are picked up and known zones keep their provider
+	zones = map[string]string{"example.com": "zone-1", "example.net": "zone-3"}
+	second, err := source.Zones()
+	if err != nil {
+		t.Fatalf("Zones() error = %v", err)
+	}
+	if names := slices.Sorted(maps.Keys(second)); !slices.Equal(names, []string{"example.com", "example.net"}) {
+		t.Fatalf("Zones() = %v, want example.com and example.net", names)
+	}
+	if &second["example.com"][0] != &first["example.com"][0] {
+		t.Error("Zones() created a new provider for a known zone")
+	}
+
+	// A zone that was recreated gets a new provider
+	zones = map[string]string{"example.com": "zone-4", "example.net": "zone-3"}
+	third, err := source.Zones()
+	if err != nil {
+		t.Fatalf("Zones() error = %v", err)
+	}
+	if &third["example.com"][0] == &second["example.com"][0] {
+		t.Error("Zones() kept the provider of a recreated zone")
+	}
+
+	// Failed listings keep the zones of the previous run
+	zones = nil
+	fourth, err := source.Zones()
+	if err == nil {
+		t.Error("Zones() succeeded although listing failed")
+	}
+	if names := slices.Sorted(maps.Keys(fourth)); !slices.Equal(names, []string{"example.com", "example.net"}) {
+		t.Errorf("Zones() = %v, want the zones of the previous run", names)
+	}
+}
+
+func TestNewZoneSourceRequiresCloudflare(t *testing.T) {
+	if _, err := NewZoneSource(&config.Zone{Provider: PowerDNS}, false); err == nil {
+		t.Error("NewZoneSource() accepted a provider without zone discovery")
+	}
+	if _, err := NewZoneSource(&config.Zone{Provider: Cloudflare, ZoneID: "zone-1"}, false); err == nil {
+		t.Error("NewZoneSource() accepted a zone id without zone name")
+	}
+}
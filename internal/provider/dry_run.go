@@ -11,12 +11,28 @@ type dryRunProvider struct {
 	dns.Provider
 }
 
-func NewDryRunProvider(p dns.Provider) dryRunProvider {
+// dryRunTunnelProvider additionally looks up tunnels, without changing their ingress rules
+type dryRunTunnelProvider struct {
+	dryRunProvider
+	tunnelProvider dns.TunnelProvider
+}
+
+func NewDryRunProvider(p dns.Provider) dns.Provider {
+	if tunnelProvider, ok := p.(dns.TunnelProvider); ok {
+		return dryRunTunnelProvider{
+			dryRunProvider: dryRunProvider{Provider: p},
+			tunnelProvider: tunnelProvider,
+		}
+	}
 	return dryRunProvider{
 		Provider: p,
 	}
 }
 
+func (drp dryRunProvider) Capabilities() dns.Capabilities {
+	return dns.CapabilitiesOf(drp.Provider)
+}
+
 func (drp dryRunProvider) Create(record dns.Record) (dns.Record, error) {
 	logDryRunRecordAction("CREATE", record)
 	return record, nil
@@ -32,6 +48,15 @@ func (drp dryRunProvider) Delete(record dns.Record) error {
 	return nil
 }
 
+func (drp dryRunTunnelProvider) TunnelTarget(tunnel string) (string, error) {
+	return drp.tunnelProvider.TunnelTarget(tunnel)
+}
+
+func (drp dryRunTunnelProvider) RouteIngress(tunnel string, services map[string]string, managed []string) error {
+	slog.Info("DRY RUN ROUTE INGRESS", slog.String("tunnel", tunnel), slog.Any("services", services), slog.Any("managed", managed))
+	return nil
+}
+
 func logDryRunRecordAction(msg string, record dns.Record) {
 	slog.Info(fmt.Sprintf("DRY RUN %v", msg),
 		slog.String("ID", record.ID),
@@ -0,0 +1,227 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const (
+	defaultApiURL = "https://www.duckdns.org/update"
+	domainSuffix  = ".duckdns.org"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeTXT},
+}
+
+type duckdnsProvider struct {
+	apiURL   string
+	token    string
+	client   *http.Client
+	resolver resolver
+}
+
+// resolver looks up the current values, implemented by net.Resolver
+type resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+func New(apiURL, token string) (duckdnsProvider, error) {
+	if token == "" {
+		return duckdnsProvider{}, errors.New("no duckdns token set")
+	}
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+
+	return duckdnsProvider{
+		apiURL:   apiURL,
+		token:    token,
+		client:   jsonapi.NewHTTPClient(),
+		resolver: net.DefaultResolver,
+	}, nil
+}
+
+func (p duckdnsProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List is not supported by DuckDNS, there is no API to enumerate the subdomains of an account.
+// Therefore unknown records are never purged.
+func (p duckdnsProvider) List() ([]dns.Record, error) {
+	return nil, nil
+}
+
+// Get resolves the current value through DNS, as DuckDNS has no API to read records
+func (p duckdnsProvider) Get(domain, recordType string) (dns.Record, error) {
+	if _, err := subdomain(domain); err != nil {
+		return dns.Record{}, err
+	}
+
+	var values []string
+	var err error
+	switch recordType {
+	case constants.RecordTypeA, constants.RecordTypeAAAA:
+		network := "ip4"
+		if recordType == constants.RecordTypeAAAA {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = p.resolver.LookupIP(context.Background(), network, domain)
+		for _, ip := range ips {
+			values = append(values, ip.String())
+		}
+	case constants.RecordTypeTXT:
+		values, err = p.resolver.LookupTXT(context.Background(), domain)
+	default:
+		return dns.Record{}, fmt.Errorf("record type %s is not supported", recordType)
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return dns.Record{}, nil
+	}
+	if err != nil {
+		return dns.Record{}, err
+	}
+	if len(values) == 0 || values[0] == "" {
+		return dns.Record{}, nil
+	}
+
+	return dns.Record{
+		// Every subdomain holds at most one value per type
+		ID:      dns.RecordID(domain, recordType),
+		Name:    domain,
+		Type:    recordType,
+		Content: values[0],
+	}, nil
+}
+
+func (p duckdnsProvider) Create(record dns.Record) (dns.Record, error) {
+	return p.setChanged(record)
+}
+
+func (p duckdnsProvider) Update(record dns.Record) (dns.Record, error) {
+	return p.setChanged(record)
+}
+
+func (p duckdnsProvider) Delete(record dns.Record) error {
+	sub, err := subdomain(record.Name)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{"domains": {sub}, "clear": {"true"}}
+	if record.Type == constants.RecordTypeTXT {
+		params.Set("txt", "")
+		return p.update(params)
+	}
+
+	// Clearing always removes both addresses, so the other address type has to be restored afterwards
+	otherType := constants.RecordTypeAAAA
+	if record.Type == constants.RecordTypeAAAA {
+		otherType = constants.RecordTypeA
+	}
+	other, err := p.Get(record.Name, otherType)
+	if err != nil {
+		return err
+	}
+
+	if err := p.update(params); err != nil {
+		return err
+	}
+	if other.Content != "" {
+		_, err = p.set(other)
+	}
+	return err
+}
+
+// setChanged skips values which already resolve to the record content. As List returns no records, TXT values are
+// planned as new records on every run and would be sent again otherwise.
+func (p duckdnsProvider) setChanged(record dns.Record) (dns.Record, error) {
+	if err := capabilities.Validate(record); err != nil {
+		return dns.Record{}, fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+
+	current, err := p.Get(record.Name, record.Type)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	if current.Content == record.Content {
+		slog.Debug("Value is already set, skipping update", "name", record.Name, "type", record.Type)
+		record.ID = current.ID
+		return record, nil
+	}
+	return p.set(record)
+}
+
+func (p duckdnsProvider) set(record dns.Record) (dns.Record, error) {
+	if err := capabilities.Validate(record); err != nil {
+		return dns.Record{}, fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+	sub, err := subdomain(record.Name)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	params := url.Values{"domains": {sub}}
+	switch record.Type {
+	case constants.RecordTypeA:
+		params.Set("ip", record.Content)
+	case constants.RecordTypeAAAA:
+		params.Set("ipv6", record.Content)
+	case constants.RecordTypeTXT:
+		params.Set("txt", record.Content)
+	}
+
+	if err := p.update(params); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p duckdnsProvider) update(params url.Values) error {
+	params.Set("token", p.token)
+
+	resp, err := p.client.Get(p.apiURL + "?" + params.Encode())
+	if err != nil {
+		// Don't leak the token, which is part of the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(strings.TrimSpace(string(body)), "OK") {
+		return fmt.Errorf("duckdns rejected the update of %s: %s", params.Get("domains"), strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// subdomain returns the DuckDNS subdomain of a name, e.g. 'myhome' for 'myhome.duckdns.org'
+func subdomain(name string) (string, error) {
+	sub, found := strings.CutSuffix(name, domainSuffix)
+	if !found || sub == "" || strings.Contains(sub, ".") {
+		return "", fmt.Errorf("%s is not a duckdns subdomain, expected <sub>%s", name, domainSuffix)
+	}
+	return sub, nil
+}
@@ -0,0 +1,181 @@
+package duckdns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeResolver returns the values of its maps, names without values are not found
+type fakeResolver struct {
+	ips  map[string][]net.IP
+	txts map[string][]string
+}
+
+func (f fakeResolver) LookupIP(_ context.Context, network, host string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, ip := range f.ips[host] {
+		if (ip.To4() != nil) == (network == "ip4") {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+func (f fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if len(f.txts[name]) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return f.txts[name], nil
+}
+
+// fakeDuckDNS records the query of every update request
+type fakeDuckDNS struct {
+	updates []url.Values
+}
+
+func (f *fakeDuckDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("token") != "token" {
+		w.Write([]byte("KO"))
+		return
+	}
+	query.Del("token")
+	f.updates = append(f.updates, query)
+	w.Write([]byte("OK"))
+}
+
+func newTestProvider(t *testing.T, fake *fakeDuckDNS, resolver fakeResolver) duckdnsProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.resolver = resolver
+	return p
+}
+
+func TestCreateSkipsUnchangedValues(t *testing.T) {
+	fake := &fakeDuckDNS{}
+	p := newTestProvider(t, fake, fakeResolver{
+		ips:  map[string][]net.IP{"home.duckdns.org": {net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")}},
+		txts: map[string][]string{"home.duckdns.org": {"token=1"}},
+	})
+
+	unchanged := []dns.Record{
+		{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1"},
+		{Name: "home.duckdns.org", Type: constants.RecordTypeAAAA, Content: "2001:db8::1"},
+		{Name: "home.duckdns.org", Type: constants.RecordTypeTXT, Content: "token=1"},
+	}
+	for _, record := range unchanged {
+		created, err := p.Create(record)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if created.ID != dns.RecordID(record.Name, record.Type) {
+			t.Errorf("Create() ID = %s, want %s", created.ID, dns.RecordID(record.Name, record.Type))
+		}
+	}
+	if len(fake.updates) != 0 {
+		t.Fatalf("sent %v, want no updates", fake.updates)
+	}
+
+	if _, err := p.Update(dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeTXT, Content: "token=2"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := p.Create(dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.2"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	want := []string{"domains=home&txt=token%3D2", "domains=home&ip=203.0.113.2"}
+	if len(fake.updates) != len(want) {
+		t.Fatalf("sent %v, want %v", fake.updates, want)
+	}
+	for i, update := range fake.updates {
+		if update.Encode() != want[i] {
+			t.Errorf("update %d = %s, want %s", i, update.Encode(), want[i])
+		}
+	}
+}
+
+func TestDeleteRestoresOtherAddress(t *testing.T) {
+	fake := &fakeDuckDNS{}
+	p := newTestProvider(t, fake, fakeResolver{
+		ips: map[string][]net.IP{"home.duckdns.org": {net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")}},
+	})
+
+	if err := p.Delete(dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	// The resolved AAAA value is sent again, although it did not change
+	want := []string{"clear=true&domains=home", "domains=home&ipv6=2001%3Adb8%3A%3A1"}
+	if len(fake.updates) != len(want) {
+		t.Fatalf("sent %v, want %v", fake.updates, want)
+	}
+	for i, update := range fake.updates {
+		if update.Encode() != want[i] {
+			t.Errorf("update %d = %s, want %s", i, update.Encode(), want[i])
+		}
+	}
+}
+
+func TestRejectsUnsupportedRecords(t *testing.T) {
+	fake := &fakeDuckDNS{}
+	p := newTestProvider(t, fake, fakeResolver{})
+
+	tests := []struct {
+		name   string
+		record dns.Record
+		want   string
+	}{
+		{"cname", dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeCNAME, Content: "example.com"}, "record type CNAME is not supported"},
+		{"proxied", dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1", Proxied: true}, "proxied records are not supported"},
+		{"comment", dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1", Comment: "home"}, "record comments are not supported"},
+		{"ttl", dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1", TTL: 60}, "custom TTLs are not supported"},
+		{"foreign domain", dns.Record{Name: "home.example.com", Type: constants.RecordTypeA, Content: "203.0.113.1"}, "is not a duckdns subdomain"},
+		{"nested subdomain", dns.Record{Name: "app.home.duckdns.org", Type: constants.RecordTypeA, Content: "203.0.113.1"}, "is not a duckdns subdomain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Create(tt.record)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Create() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if len(fake.updates) != 0 {
+		t.Errorf("sent %v, want no updates", fake.updates)
+	}
+}
+
+func TestRejectedUpdateDoesNotLeakToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("KO"))
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.resolver = fakeResolver{}
+
+	_, err = p.Create(dns.Record{Name: "home.duckdns.org", Type: constants.RecordTypeTXT, Content: "token=1"})
+	if err == nil || !strings.Contains(err.Error(), "duckdns rejected the update of home") {
+		t.Fatalf("Create() error = %v, want a rejected update", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Create() error = %v contains the token", err)
+	}
+}
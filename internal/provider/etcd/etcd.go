@@ -0,0 +1,280 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultPrefix = "/skydns"
+	dialTimeout   = 5 * time.Second
+
+	// Records written by dockdns are stored below the name in keys starting with leafPrefix,
+	// so several values can exist for one name
+	leafPrefix = "dockdns-"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT},
+	TTL:         true,
+}
+
+type etcdProvider struct {
+	client *clientv3.Client
+	prefix string
+	zone   string
+}
+
+// service is the SkyDNS message format read by the CoreDNS etcd plugin
+type service struct {
+	Host string `json:"host,omitempty"`
+	TTL  uint32 `json:"ttl,omitempty"`
+	Mail bool   `json:"mail,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+func New(endpoints []string, username, password, prefix, zone string) (etcdProvider, error) {
+	if len(endpoints) == 0 {
+		return etcdProvider{}, fmt.Errorf("no etcd endpoints set for zone %s", zone)
+	}
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		Username:    username,
+		Password:    password,
+		DialTimeout: dialTimeout,
+	})
+	if err != nil {
+		return etcdProvider{}, fmt.Errorf("could not create etcd client: %w", err)
+	}
+
+	return etcdProvider{
+		client: client,
+		prefix: "/" + strings.Trim(prefix, "/"),
+		zone:   zone,
+	}, nil
+}
+
+func (p etcdProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List walks all keys below the zone and returns address, alias and TXT entries. Mail entries and keys
+// that don't hold a SkyDNS message are skipped
+func (p etcdProvider) List() ([]dns.Record, error) {
+	return p.records(p.path(p.zone))
+}
+
+func (p etcdProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.records(p.path(domain))
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p etcdProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = p.key(record)
+	return record, nil
+}
+
+func (p etcdProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = p.key(record)
+	return record, nil
+}
+
+func (p etcdProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply writes all changes in a single transaction. Keys are written without lease, the TTL of a record only goes to the
+// ttl field served by CoreDNS. A lease would have to be kept alive by dockdns, so its records would expire whenever
+// dockdns is stopped, while records that are no longer configured are removed by the regular reconciliation anyway.
+func (p etcdProvider) Apply(changes []dns.Change) error {
+	// etcd rejects transactions touching a key twice, so only the last operation per key is kept
+	var keys []string
+	ops := map[string]clientv3.Op{}
+	addOp := func(key string, op clientv3.Op) {
+		if _, exists := ops[key]; !exists {
+			keys = append(keys, key)
+		}
+		ops[key] = op
+	}
+
+	for _, change := range changes {
+		record := change.Record
+		if change.Action == dns.ActionDelete {
+			key := record.ID
+			if key == "" {
+				key = p.key(record)
+			}
+			addOp(key, clientv3.OpDelete(key))
+			continue
+		}
+
+		if err := capabilities.Validate(record); err != nil {
+			return fmt.Errorf("invalid record %s: %w", record.Name, err)
+		}
+		value, err := json.Marshal(toService(record))
+		if err != nil {
+			return err
+		}
+
+		key := p.key(record)
+		if change.Action == dns.ActionUpdate {
+			// An update replaces all values of the name and type
+			existing, err := p.records(p.path(record.Name))
+			if err != nil {
+				return err
+			}
+			for _, r := range existing {
+				if strings.EqualFold(r.Name, record.Name) && r.Type == record.Type && r.ID != key {
+					addOp(r.ID, clientv3.OpDelete(r.ID))
+				}
+			}
+		}
+		addOp(key, clientv3.OpPut(key, string(value)))
+	}
+
+	txnOps := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		txnOps = append(txnOps, ops[key])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	_, err := p.client.Txn(ctx).Then(txnOps...).Commit()
+	if err != nil {
+		return fmt.Errorf("etcd transaction failed: %w", err)
+	}
+	return nil
+}
+
+// records returns the records stored at the path and below it
+func (p etcdProvider) records(path string) ([]dns.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s from etcd: %w", path, err)
+	}
+
+	var records []dns.Record
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		// The prefix /skydns/com/example also matches /skydns/com/example2
+		if key != path && !strings.HasPrefix(key, path+"/") {
+			continue
+		}
+
+		var svc service
+		if err := json.Unmarshal(kv.Value, &svc); err != nil {
+			slog.Debug("skipping key with invalid SkyDNS message", "key", key, "error", err)
+			continue
+		}
+		if record, ok := p.mapRecord(key, svc); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p etcdProvider) mapRecord(key string, svc service) (dns.Record, bool) {
+	if svc.Host == "" && svc.Text != "" {
+		return dns.Record{
+			ID:      key,
+			Name:    p.name(key),
+			Type:    constants.RecordTypeTXT,
+			Content: svc.Text,
+			TTL:     int(svc.TTL),
+		}, true
+	}
+	if svc.Host == "" || svc.Mail {
+		return dns.Record{}, false
+	}
+
+	var recordType string
+	ip := net.ParseIP(svc.Host)
+	switch {
+	case ip == nil:
+		recordType = constants.RecordTypeCNAME
+	case ip.To4() != nil:
+		recordType = constants.RecordTypeA
+	default:
+		recordType = constants.RecordTypeAAAA
+	}
+
+	return dns.Record{
+		ID:      key,
+		Name:    p.name(key),
+		Type:    recordType,
+		Content: strings.TrimSuffix(svc.Host, "."),
+		TTL:     int(svc.TTL),
+	}, true
+}
+
+// path converts a domain name to its SkyDNS key, e.g. www.example.com to /skydns/com/example/www
+func (p etcdProvider) path(name string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(name, ".")), ".")
+	slices.Reverse(labels)
+	return p.prefix + "/" + strings.Join(labels, "/")
+}
+
+// name converts a key back to the domain name, leaving out the leaf written by dockdns
+func (p etcdProvider) name(key string) string {
+	labels := strings.Split(strings.TrimPrefix(key, p.prefix+"/"), "/")
+	if strings.HasPrefix(labels[len(labels)-1], leafPrefix) {
+		labels = labels[:len(labels)-1]
+	}
+	slices.Reverse(labels)
+	return strings.Join(labels, ".")
+}
+
+// The key of a record is derived from its value, so records of one name don't overwrite each other
+func (p etcdProvider) key(record dns.Record) string {
+	content := record.Content
+	if record.Type != constants.RecordTypeTXT {
+		content = strings.ToLower(content)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(content))
+	return fmt.Sprintf("%s/%s%s-%08x", p.path(record.Name), leafPrefix, strings.ToLower(record.Type), h.Sum32())
+}
+
+func toService(record dns.Record) service {
+	if record.Type == constants.RecordTypeTXT {
+		return service{Text: record.Content, TTL: uint32(record.TTL)}
+	}
+	return service{
+		Host: record.Content,
+		TTL:  uint32(record.TTL),
+	}
+}
@@ -0,0 +1,137 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// newTestProvider connects to the etcd set in DOCKDNS_TEST_ETCD_ENDPOINTS, e.g. a local single node started with
+// `etcd --data-dir $(mktemp -d)`. The tests write below /skydns/com/example and are skipped without an endpoint.
+func newTestProvider(t *testing.T) etcdProvider {
+	endpoints := os.Getenv("DOCKDNS_TEST_ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("DOCKDNS_TEST_ETCD_ENDPOINTS not set")
+	}
+
+	p, err := New(strings.Split(endpoints, ","), "", "", "", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.client.Close() })
+
+	clean := func() {
+		for _, prefix := range []string{"/skydns/com/example/", "/skydns/com/example2/"} {
+			if _, err := p.client.Delete(context.Background(), prefix, clientv3.WithPrefix()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	clean()
+	t.Cleanup(clean)
+	return p
+}
+
+func (p etcdProvider) put(t *testing.T, key, value string) {
+	if _, err := p.client.Put(context.Background(), key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sortByID(records []dns.Record) []dns.Record {
+	slices.SortFunc(records, func(a, b dns.Record) int { return strings.Compare(a.ID, b.ID) })
+	return records
+}
+
+func TestApply(t *testing.T) {
+	p := newTestProvider(t)
+	// Entries written by hand, mail entries are not mapped and other zones are not listed
+	p.put(t, "/skydns/com/example/txt", `{"text":"v=spf1 -all","ttl":300}`)
+	p.put(t, "/skydns/com/example/mail", `{"host":"mx.example.com","mail":true}`)
+	p.put(t, "/skydns/com/example2/www", `{"host":"10.0.0.9"}`)
+
+	records := []dns.Record{
+		{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeAAAA, Content: "fd00::1", TTL: 300},
+		{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 60},
+		{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=1", TTL: 300},
+	}
+	var changes []dns.Change
+	for _, record := range records {
+		changes = append(changes, dns.Change{Action: dns.ActionCreate, Record: record})
+	}
+	if err := p.Apply(changes); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	got, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dns.Record{{ID: "/skydns/com/example/txt", Name: "txt.example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300}}
+	for _, record := range records {
+		record.ID = p.key(record)
+		want = append(want, record)
+	}
+	if !reflect.DeepEqual(sortByID(got), sortByID(want)) {
+		t.Errorf("List() = %+v, want %+v", got, want)
+	}
+
+	// An update replaces all values of the name and type
+	updated := dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.3", TTL: 60}
+	if _, err := p.Update(updated); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	resp, err := p.client.Get(context.Background(), "/skydns/com/example/", clientv3.WithPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, kv := range resp.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	updated.ID = p.key(updated)
+	wantKeys := []string{updated.ID, p.key(records[2]), p.key(records[4]), "/skydns/com/example/mail", "/skydns/com/example/txt"}
+	slices.Sort(wantKeys)
+	if !slices.Equal(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+
+	record, err := p.Get("example.com", constants.RecordTypeA)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if record != updated {
+		t.Errorf("Get() = %+v, want %+v", record, updated)
+	}
+}
+
+func TestApplyRejectsUnsupportedRecords(t *testing.T) {
+	p := newTestProvider(t)
+	err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10}}})
+	if err == nil {
+		t.Error("Apply() accepted an MX record")
+	}
+}
+
+func TestPath(t *testing.T) {
+	p := etcdProvider{prefix: defaultPrefix}
+	if got := p.path("WWW.Example.com."); got != "/skydns/com/example/www" {
+		t.Errorf("path() = %s, want /skydns/com/example/www", got)
+	}
+	if got := p.name("/skydns/com/example/www/dockdns-a-01234567"); got != "www.example.com" {
+		t.Errorf("name() = %s, want www.example.com", got)
+	}
+}
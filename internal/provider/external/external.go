@@ -0,0 +1,180 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const (
+	OperationList   = "list"
+	OperationGet    = "get"
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// timeout bounds every call of the command or endpoint
+var timeout = 30 * time.Second
+
+// transport sends the JSON encoded request for an operation and returns the raw JSON response
+type transport func(operation string, request any) ([]byte, error)
+
+// DefaultCapabilities are used for the fields not set in the configuration. The backend is expected to store all
+// record types with their TTL, comments and the proxy setting have to be enabled explicitly.
+var DefaultCapabilities = dns.Capabilities{TTL: true}
+
+// externalProvider delegates all operations to a user supplied executable or HTTP endpoint
+type externalProvider struct {
+	call         transport
+	capabilities dns.Capabilities
+}
+
+type getRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// NewExec creates a provider that runs the command with the operation as last argument.
+// The request is written to stdin, the response is read from stdout.
+func NewExec(command, zone string, capabilities *dns.Capabilities) (externalProvider, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return externalProvider{}, fmt.Errorf("no command set for zone %s", zone)
+	}
+
+	call := func(operation string, request any) ([]byte, error) {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], append(slices.Clone(args[1:]), operation)...)
+		cmd.Env = append(os.Environ(), "DOCKDNS_ZONE="+zone)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s %s failed: %w: %s", args[0], operation, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.Bytes(), nil
+	}
+	return newProvider(call, capabilities), nil
+}
+
+// NewHTTP creates a provider that POSTs the request to <apiURL>/<operation>
+func NewHTTP(apiURL, apiToken, zone string, capabilities *dns.Capabilities) (externalProvider, error) {
+	if apiURL == "" {
+		return externalProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	client := &http.Client{Timeout: timeout}
+
+	call := func(operation string, request any) ([]byte, error) {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, apiURL+"/"+operation, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-DockDNS-Zone", zone)
+		if apiToken != "" {
+			req.Header.Set("Authorization", "Bearer "+apiToken)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil, fmt.Errorf("%s returned %s: %s", req.URL, resp.Status, strings.TrimSpace(string(respBody)))
+		}
+		return respBody, nil
+	}
+	return newProvider(call, capabilities), nil
+}
+
+func newProvider(call transport, capabilities *dns.Capabilities) externalProvider {
+	if capabilities == nil {
+		capabilities = &DefaultCapabilities
+	}
+	return externalProvider{call: call, capabilities: *capabilities}
+}
+
+func (p externalProvider) Capabilities() dns.Capabilities {
+	return p.capabilities
+}
+
+func (p externalProvider) List() ([]dns.Record, error) {
+	var records []dns.Record
+	err := p.do(OperationList, struct{}{}, &records)
+	return records, err
+}
+
+// Get returns an empty record, if the response is empty or null
+func (p externalProvider) Get(name, recordType string) (dns.Record, error) {
+	var record dns.Record
+	err := p.do(OperationGet, getRequest{Name: name, Type: recordType}, &record)
+	return record, err
+}
+
+func (p externalProvider) Create(record dns.Record) (dns.Record, error) {
+	return p.write(OperationCreate, record)
+}
+
+func (p externalProvider) Update(record dns.Record) (dns.Record, error) {
+	return p.write(OperationUpdate, record)
+}
+
+func (p externalProvider) Delete(record dns.Record) error {
+	return p.do(OperationDelete, record, nil)
+}
+
+// write sends the record and returns the record from the response. Without a response body, the sent record is returned
+func (p externalProvider) write(operation string, record dns.Record) (dns.Record, error) {
+	result := record
+	if err := p.do(operation, record, &result); err != nil {
+		return dns.Record{}, err
+	}
+	return result, nil
+}
+
+func (p externalProvider) do(operation string, request, result any) error {
+	response, err := p.call(operation, request)
+	if err != nil {
+		return err
+	}
+
+	if result == nil || len(bytes.TrimSpace(response)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response, result); err != nil {
+		return fmt.Errorf("invalid response for %s: %w", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package external
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+var record = dns.Record{
+	ID:       "42",
+	Name:     "mail.example.com",
+	Content:  "mx.example.com",
+	Type:     constants.RecordTypeMX,
+	Proxied:  true,
+	TTL:      300,
+	Comment:  "managed by dockdns",
+	Priority: 10,
+}
+
+// writeScript writes an executable shell script and returns its path
+func writeScript(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "backend.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExec(t *testing.T) {
+	// Echoes the request of create, answers list with the zone and fails on delete
+	script := writeScript(t, `case "$2" in
+create) cat ;;
+list) echo "[{\"name\":\"$DOCKDNS_ZONE\",\"type\":\"$1\"}]" ;;
+get) ;;
+delete) echo "no such record" >&2; exit 3 ;;
+esac
+`)
+	p, err := NewExec(script+" A", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := p.Create(record)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created != record {
+		t.Errorf("Create() = %+v, want %+v", created, record)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Name != "example.com" || records[0].Type != "A" {
+		t.Errorf("List() = %+v, want the zone and the configured argument", records)
+	}
+
+	existing, err := p.Get("www.example.com", constants.RecordTypeA)
+	if err != nil || existing != (dns.Record{}) {
+		t.Errorf("Get() = %+v, %v, want an empty record", existing, err)
+	}
+
+	err = p.Delete(record)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "no such record") {
+		t.Errorf("Delete() error = %v, want the exit status and stderr", err)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	defer func(previous time.Duration) { timeout = previous }(timeout)
+	timeout = 100 * time.Millisecond
+
+	p, err := NewExec(writeScript(t, "exec sleep 10\n"), "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := p.List(); err == nil {
+		t.Error("List() succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("List() took %s, want it to be killed after the timeout", elapsed)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-DockDNS-Zone") != "example.com" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/update":
+			io.Copy(w, r.Body)
+		case "/api/get":
+			w.Write([]byte("null"))
+		case "/api/create":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unknown operation", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := NewHTTP(server.URL+"/api/", "secret", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := p.Update(record)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated != record {
+		t.Errorf("Update() = %+v, want %+v", updated, record)
+	}
+
+	// Without a response body, the sent record is returned
+	created, err := p.Create(record)
+	if err != nil || created != record {
+		t.Errorf("Create() = %+v, %v, want %+v", created, err, record)
+	}
+
+	existing, err := p.Get("www.example.com", constants.RecordTypeA)
+	if err != nil || existing != (dns.Record{}) {
+		t.Errorf("Get() = %+v, %v, want an empty record", existing, err)
+	}
+
+	err = p.Delete(record)
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") || !strings.Contains(err.Error(), "unknown operation") {
+		t.Errorf("Delete() error = %v, want the status and the response body", err)
+	}
+
+	p, err = NewHTTP(server.URL+"/api", "wrong", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("List() error = %v, want 401 Unauthorized", err)
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	defer func(previous time.Duration) { timeout = previous }(timeout)
+	timeout = 100 * time.Millisecond
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(server.Close)
+	defer close(done)
+
+	p, err := NewHTTP(server.URL, "", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.List(); err == nil {
+		t.Error("List() succeeded, want a timeout")
+	}
+}
+
+func TestInvalidResponse(t *testing.T) {
+	p := newProvider(func(string, any) ([]byte, error) { return []byte("not json"), nil }, nil)
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "invalid response for list") {
+		t.Errorf("List() error = %v, want an invalid response", err)
+	}
+}
+
+func TestRecordJSON(t *testing.T) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"42","name":"mail.example.com","content":"mx.example.com","type":"MX","proxied":true,"ttl":300,"comment":"managed by dockdns","priority":10}`
+	if string(b) != want {
+		t.Errorf("json = %s, want %s", b, want)
+	}
+
+	var decoded dns.Record
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != record {
+		t.Errorf("decoded = %+v, want %+v", decoded, record)
+	}
+}
+
+func TestDefaultCapabilities(t *testing.T) {
+	p := newProvider(nil, nil)
+	if err := p.Capabilities().Validate(dns.Record{Type: constants.RecordTypeTXT, TTL: 60}); err != nil {
+		t.Errorf("Validate() error = %v, want all types with TTL", err)
+	}
+	if err := p.Capabilities().Validate(dns.Record{Type: constants.RecordTypeA, Comment: "dockdns"}); err == nil {
+		t.Error("Validate() accepted a comment without configured support")
+	}
+
+	p = newProvider(nil, &dns.Capabilities{Comment: true, Proxied: true})
+	if err := p.Capabilities().Validate(dns.Record{Type: constants.RecordTypeA, Comment: "dockdns", Proxied: true}); err != nil {
+		t.Errorf("Validate() error = %v, want comments and proxied records", err)
+	}
+}
@@ -0,0 +1,231 @@
+package gcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	gdns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type gcloudProvider struct {
+	project     string
+	managedZone string
+	service     *gdns.Service
+}
+
+func New(apiURL, credentialsFile, project, managedZone string) (gcloudProvider, error) {
+	service, project, err := newService(apiURL, credentialsFile, project)
+	if err != nil {
+		return gcloudProvider{}, err
+	}
+
+	return gcloudProvider{
+		project:     project,
+		managedZone: managedZone,
+		service:     service,
+	}, nil
+}
+
+// FetchManagedZone returns the name of the managed zone serving the domain
+func FetchManagedZone(apiURL, credentialsFile, project, domain string) (string, error) {
+	service, project, err := newService(apiURL, credentialsFile, project)
+	if err != nil {
+		return "", err
+	}
+
+	zones, err := service.ManagedZones.List(project).DnsName(fqdn(domain)).Do()
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range zones.ManagedZones {
+		if strings.EqualFold(zone.DnsName, fqdn(domain)) {
+			return zone.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no managed zone found for domain %s in project %s", domain, project)
+}
+
+// newService creates the Cloud DNS client. Without credentials file, the application default credentials are used.
+// If no project is given, the project of the service account is used.
+func newService(apiURL, credentialsFile, project string) (*gdns.Service, string, error) {
+	opts := []option.ClientOption{option.WithScopes(gdns.NdevClouddnsReadwriteScope)}
+	if credentialsFile != "" {
+		content, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read credentials file: %w", err)
+		}
+		var serviceAccount struct {
+			ProjectID string `json:"project_id"`
+		}
+		if err := json.Unmarshal(content, &serviceAccount); err != nil {
+			return nil, "", fmt.Errorf("invalid credentials file %s: %w", credentialsFile, err)
+		}
+		if project == "" {
+			project = serviceAccount.ProjectID
+		}
+		opts = append(opts, option.WithAuthCredentialsJSON(option.ServiceAccount, content))
+	}
+	if apiURL != "" {
+		opts = append(opts, option.WithEndpoint(apiURL))
+	}
+	if project == "" {
+		return nil, "", fmt.Errorf("no google cloud project set")
+	}
+
+	service, err := gdns.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create cloud dns client: %w", err)
+	}
+	return service, project, nil
+}
+
+func (p gcloudProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p gcloudProvider) List() ([]dns.Record, error) {
+	var records []dns.Record
+
+	err := p.service.ResourceRecordSets.List(p.project, p.managedZone).Pages(context.Background(), func(page *gdns.ResourceRecordSetsListResponse) error {
+		for _, set := range page.Rrsets {
+			if capabilities.Supports(set.Type) {
+				records = append(records, mapRecords(set)...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p gcloudProvider) Get(domain, recordType string) (dns.Record, error) {
+	set, err := p.recordSet(domain, recordType)
+	if err != nil || set == nil {
+		return dns.Record{}, err
+	}
+
+	records := mapRecords(set)
+	if len(records) == 0 {
+		return dns.Record{}, nil
+	}
+	return records[0], nil
+}
+
+func (p gcloudProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p gcloudProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p gcloudProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply sends all changes as one transactional change. Cloud DNS manages whole record sets,
+// so all changes to the same name and type are merged into one set, which replaces the existing one.
+func (p gcloudProvider) Apply(changes []dns.Change) error {
+	// Deletions must match the existing record set exactly, so the sets are kept as read
+	existingSets := map[string]*gdns.ResourceRecordSet{}
+	fetch := func(name, recordType string) (*dns.RRSet, error) {
+		existing, err := p.recordSet(name, recordType)
+		if err != nil || existing == nil {
+			return nil, err
+		}
+		existingSets[dns.RecordID(name, recordType)] = existing
+		return &dns.RRSet{Name: name, Type: recordType, TTL: int(existing.Ttl), Values: existing.Rrdatas}, nil
+	}
+
+	sets, err := dns.MergeRRSets(changes, fetch, dns.PresentationValue)
+	if err != nil {
+		return err
+	}
+
+	change := &gdns.Change{}
+	for _, set := range sets {
+		desired := set.Desired
+		if existing := existingSets[dns.RecordID(desired.Name, desired.Type)]; existing != nil {
+			change.Deletions = append(change.Deletions, existing)
+		}
+		if len(desired.Values) > 0 {
+			change.Additions = append(change.Additions, &gdns.ResourceRecordSet{
+				Name:    fqdn(desired.Name),
+				Type:    desired.Type,
+				Ttl:     int64(desired.TTL),
+				Rrdatas: desired.Values,
+			})
+		}
+	}
+	if len(change.Additions) == 0 && len(change.Deletions) == 0 {
+		return nil
+	}
+
+	_, err = p.service.Changes.Create(p.project, p.managedZone, change).Do()
+	return err
+}
+
+// recordSet returns the record set with the given name and type, or nil if it does not exist
+func (p gcloudProvider) recordSet(domain, recordType string) (*gdns.ResourceRecordSet, error) {
+	sets, err := p.service.ResourceRecordSets.List(p.project, p.managedZone).Name(fqdn(domain)).Type(recordType).Do()
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets.Rrsets {
+		if strings.EqualFold(set.Name, fqdn(domain)) && set.Type == recordType {
+			return set, nil
+		}
+	}
+	return nil, nil
+}
+
+func mapRecords(set *gdns.ResourceRecordSet) []dns.Record {
+	var records []dns.Record
+
+	name := strings.TrimSuffix(set.Name, ".")
+	for _, value := range set.Rrdatas {
+		var priority int
+		switch set.Type {
+		case constants.RecordTypeCNAME:
+			value = strings.TrimSuffix(value, ".")
+		case constants.RecordTypeTXT:
+			value = dns.UnquoteTXT(value)
+		case constants.RecordTypeMX:
+			priority, value = dns.ParseMX(value)
+		}
+		records = append(records, dns.Record{
+			ID:       dns.RecordID(name, set.Type),
+			Name:     name,
+			Type:     set.Type,
+			Content:  value,
+			TTL:      int(set.Ttl),
+			Priority: priority,
+		})
+	}
+	return records
+}
+
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
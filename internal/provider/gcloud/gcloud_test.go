@@ -0,0 +1,172 @@
+package gcloud
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	gdns "google.golang.org/api/dns/v1"
+)
+
+const (
+	project     = "test-project"
+	managedZone = "example-com"
+)
+
+// fakeCloudDNS serves the record sets of a single managed zone. Like Cloud DNS, it rejects changes
+// whose deletions don't match the existing record sets exactly.
+type fakeCloudDNS struct {
+	sets    []*gdns.ResourceRecordSet
+	changes int
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "token_type": "Bearer", "expires_in": 3600})
+		return
+	}
+
+	prefix := "/dns/v1/projects/" + project + "/managedZones/" + managedZone
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/dns/v1/projects/"+project+"/managedZones":
+		json.NewEncoder(w).Encode(gdns.ManagedZonesListResponse{ManagedZones: []*gdns.ManagedZone{
+			{Name: "example-org", DnsName: "example.org."},
+			{Name: managedZone, DnsName: "example.com."},
+		}})
+	case r.Method == http.MethodGet && r.URL.Path == prefix+"/rrsets":
+		sets := f.sets
+		if name := r.URL.Query().Get("name"); name != "" {
+			sets = slices.DeleteFunc(slices.Clone(sets), func(set *gdns.ResourceRecordSet) bool {
+				return set.Name != name || set.Type != r.URL.Query().Get("type")
+			})
+		}
+		json.NewEncoder(w).Encode(gdns.ResourceRecordSetsListResponse{Rrsets: sets})
+	case r.Method == http.MethodPost && r.URL.Path == prefix+"/changes":
+		var change gdns.Change
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sets := slices.Clone(f.sets)
+		for _, deletion := range change.Deletions {
+			idx := slices.IndexFunc(sets, func(set *gdns.ResourceRecordSet) bool {
+				return set.Name == deletion.Name && set.Type == deletion.Type && set.Ttl == deletion.Ttl && slices.Equal(set.Rrdatas, deletion.Rrdatas)
+			})
+			if idx < 0 {
+				http.Error(w, `{"error":{"code":412,"message":"deletion does not match"}}`, http.StatusPreconditionFailed)
+				return
+			}
+			sets = slices.Delete(sets, idx, idx+1)
+		}
+		for _, addition := range change.Additions {
+			if slices.ContainsFunc(sets, func(set *gdns.ResourceRecordSet) bool { return set.Name == addition.Name && set.Type == addition.Type }) {
+				http.Error(w, `{"error":{"code":409,"message":"record set exists"}}`, http.StatusConflict)
+				return
+			}
+			sets = append(sets, addition)
+		}
+		f.sets = sets
+		f.changes++
+		json.NewEncoder(w).Encode(gdns.Change{Id: "1", Status: "done"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestServer starts the stand-in and returns a generated service account key for it
+func newTestServer(t *testing.T, fake *fakeCloudDNS) (*httptest.Server, string) {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     project,
+		"private_key_id": "key",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "dockdns@" + project + ".iam.gserviceaccount.com",
+		"token_uri":      server.URL + "/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialsFile := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(credentialsFile, credentials, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return server, credentialsFile
+}
+
+func newTestProvider(t *testing.T, fake *fakeCloudDNS) gcloudProvider {
+	server, credentialsFile := newTestServer(t, fake)
+	p, err := New(server.URL+"/", credentialsFile, "", managedZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeCloudDNS{sets: []*gdns.ResourceRecordSet{
+		{Name: "example.com.", Type: "TXT", Ttl: 300, Rrdatas: []string{`"v=spf1 -all"`, `"token=old"`}},
+		{Name: "old.example.com.", Type: "A", Ttl: 300, Rrdatas: []string{"10.0.0.1"}},
+	}}
+	p := newTestProvider(t, fake)
+
+	err := p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 300}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=old", TTL: 300}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "old.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 600}},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if fake.changes != 1 {
+		t.Errorf("sent %d changes, want 1", fake.changes)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dns.Record{
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300},
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 300},
+		{ID: "www.example.com/CNAME", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 600},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+}
+
+func TestFetchManagedZone(t *testing.T) {
+	server, credentialsFile := newTestServer(t, &fakeCloudDNS{})
+
+	zone, err := FetchManagedZone(server.URL+"/", credentialsFile, "", "example.com")
+	if err != nil {
+		t.Fatalf("FetchManagedZone() error = %v", err)
+	}
+	if zone != managedZone {
+		t.Errorf("FetchManagedZone() = %s, want %s", zone, managedZone)
+	}
+}
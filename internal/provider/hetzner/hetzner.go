@@ -0,0 +1,218 @@
+package hetzner
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const defaultApiURL = "https://dns.hetzner.com/api/v1"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type hetznerProvider struct {
+	client jsonapi.Client
+	zoneID string
+	zone   string
+}
+
+type zoneResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type recordBody struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+type pagination struct {
+	Meta struct {
+		Pagination struct {
+			Page     int `json:"page"`
+			LastPage int `json:"last_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+func New(apiURL, apiToken, zoneID, zone string) (hetznerProvider, error) {
+	return hetznerProvider{
+		client: newClient(apiURL, apiToken),
+		zoneID: zoneID,
+		zone:   zone,
+	}, nil
+}
+
+func (hp hetznerProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func FetchZoneID(apiURL, apiToken, domain string) (string, error) {
+	var zones struct {
+		Zones []zoneResponse `json:"zones"`
+	}
+	if err := newClient(apiURL, apiToken).Do(http.MethodGet, "/zones?name="+url.QueryEscape(domain), nil, &zones); err != nil {
+		return "", err
+	}
+
+	for _, zone := range zones.Zones {
+		if zone.Name == domain {
+			return zone.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no zone found for domain %s", domain)
+}
+
+func (hp hetznerProvider) List() ([]dns.Record, error) {
+	var allRecords []recordBody
+
+	for page := 1; ; page++ {
+		var records struct {
+			pagination
+			Records []recordBody `json:"records"`
+		}
+		path := fmt.Sprintf("/records?zone_id=%s&page=%d&per_page=100", url.QueryEscape(hp.zoneID), page)
+		if err := hp.client.Do(http.MethodGet, path, nil, &records); err != nil {
+			return nil, err
+		}
+		allRecords = append(allRecords, records.Records...)
+
+		if records.Meta.Pagination.LastPage <= page {
+			break
+		}
+	}
+
+	var mappedRecords []dns.Record
+	for _, record := range allRecords {
+		if !capabilities.Supports(record.Type) {
+			continue
+		}
+		mappedRecords = append(mappedRecords, hp.mapRecord(record))
+	}
+	return mappedRecords, nil
+}
+
+func (hp hetznerProvider) Get(domain, recordType string) (dns.Record, error) {
+	// The API does not support filtering records by name or type
+	records, err := hp.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (hp hetznerProvider) Create(record dns.Record) (dns.Record, error) {
+	var created struct {
+		Record recordBody `json:"record"`
+	}
+	if err := hp.client.Do(http.MethodPost, "/records", hp.toBody(record), &created); err != nil {
+		return dns.Record{}, err
+	}
+	return hp.mapRecord(created.Record), nil
+}
+
+func (hp hetznerProvider) Update(record dns.Record) (dns.Record, error) {
+	var updated struct {
+		Record recordBody `json:"record"`
+	}
+	if err := hp.client.Do(http.MethodPut, "/records/"+url.PathEscape(record.ID), hp.toBody(record), &updated); err != nil {
+		return dns.Record{}, err
+	}
+	return hp.mapRecord(updated.Record), nil
+}
+
+func (hp hetznerProvider) Delete(record dns.Record) error {
+	return hp.client.Do(http.MethodDelete, "/records/"+url.PathEscape(record.ID), nil, nil)
+}
+
+// Hetzner uses record names relative to the zone, '@' being the zone apex
+func (hp hetznerProvider) toBody(record dns.Record) recordBody {
+	name := dns.RelativeName(record.Name, hp.zone)
+	if name == "" {
+		name = "@"
+	}
+
+	value := record.Content
+	switch record.Type {
+	case constants.RecordTypeCNAME:
+		value = strings.TrimSuffix(value, ".") + "."
+	case constants.RecordTypeTXT:
+		value = dns.QuoteTXT(value)
+	case constants.RecordTypeMX:
+		value = dns.FormatMX(record)
+	}
+
+	return recordBody{
+		ZoneID: hp.zoneID,
+		Type:   record.Type,
+		Name:   name,
+		Value:  value,
+		TTL:    record.TTL,
+	}
+}
+
+func (hp hetznerProvider) mapRecord(r recordBody) dns.Record {
+	name := hp.zone
+	if r.Name != "@" {
+		name = r.Name + "." + hp.zone
+	}
+
+	content := r.Value
+	var priority int
+	switch r.Type {
+	case constants.RecordTypeCNAME:
+		if strings.HasSuffix(content, ".") {
+			content = strings.TrimSuffix(content, ".")
+		} else {
+			content = content + "." + hp.zone
+		}
+	case constants.RecordTypeTXT:
+		// Short values may be returned without quotes
+		content = dns.UnquoteTXT(content)
+	case constants.RecordTypeMX:
+		// Targets without trailing dot are relative to the zone
+		relative := !strings.HasSuffix(content, ".")
+		priority, content = dns.ParseMX(content)
+		if relative {
+			content = content + "." + hp.zone
+		}
+	}
+
+	return dns.Record{
+		ID:       r.ID,
+		Name:     name,
+		Type:     r.Type,
+		Content:  content,
+		TTL:      r.TTL,
+		Priority: priority,
+	}
+}
+
+func newClient(apiURL, apiToken string) jsonapi.Client {
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+	return jsonapi.New("hetzner", apiURL, func(req *http.Request) {
+		req.Header.Set("Auth-API-Token", apiToken)
+	})
+}
@@ -0,0 +1,176 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const zoneID = "zone-1"
+
+// fakeHetzner serves the records of one zone in pages of two records and stores the written records
+type fakeHetzner struct {
+	records []recordBody
+	nextID  int
+}
+
+func (f *fakeHetzner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Auth-API-Token") != "secret" {
+		http.Error(w, `{"message":"Invalid authentication credentials"}`, http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/records/")
+	idx := slices.IndexFunc(f.records, func(record recordBody) bool { return record.ID == id })
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones":
+		var zones []zoneResponse
+		if name := r.URL.Query().Get("name"); name == "example.com" {
+			zones = append(zones, zoneResponse{ID: zoneID, Name: name})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"zones": zones})
+	case r.Method == http.MethodGet && r.URL.Path == "/records":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		lastPage := max((len(f.records)+1)/2, 1)
+		records := f.records[min((page-1)*2, len(f.records)):min(page*2, len(f.records))]
+		json.NewEncoder(w).Encode(map[string]any{
+			"records": records,
+			"meta":    map[string]any{"pagination": map[string]any{"page": page, "last_page": lastPage}},
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/records":
+		var record recordBody
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		record.ID = fmt.Sprintf("new-%d", f.nextID)
+		f.records = append(f.records, record)
+		json.NewEncoder(w).Encode(map[string]any{"record": record})
+	case r.Method == http.MethodPut && idx >= 0:
+		var record recordBody
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		record.ID = id
+		f.records[idx] = record
+		json.NewEncoder(w).Encode(map[string]any{"record": record})
+	case r.Method == http.MethodDelete && idx >= 0:
+		f.records = slices.Delete(f.records, idx, idx+1)
+	default:
+		http.Error(w, `{"message":"record not found"}`, http.StatusNotFound)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeHetzner) hetznerProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "secret", zoneID, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	fake := &fakeHetzner{records: []recordBody{
+		{ID: "1", ZoneID: zoneID, Type: "NS", Name: "@", Value: "hydrogen.ns.hetzner.com."},
+		{ID: "2", ZoneID: zoneID, Type: "A", Name: "@", Value: "10.0.0.1", TTL: 300},
+		{ID: "3", ZoneID: zoneID, Type: "CNAME", Name: "www", Value: "app"},
+		{ID: "4", ZoneID: zoneID, Type: "CNAME", Name: "docs", Value: "example.org."},
+		{ID: "5", ZoneID: zoneID, Type: "TXT", Name: "@", Value: "v=spf1 -all"},
+		{ID: "6", ZoneID: zoneID, Type: "MX", Name: "@", Value: "10 mx"},
+	}}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	// Relative targets are completed with the zone, the records of all pages are listed
+	want := []dns.Record{
+		{ID: "2", Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300},
+		{ID: "3", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "app.example.com"},
+		{ID: "4", Name: "docs.example.com", Type: constants.RecordTypeCNAME, Content: "example.org"},
+		{ID: "5", Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all"},
+		{ID: "6", Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	record, err := p.Get("WWW.example.com", constants.RecordTypeCNAME)
+	if err != nil || record != want[1] {
+		t.Errorf("Get() = %+v, %v, want %+v", record, err, want[1])
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	fake := &fakeHetzner{}
+	p := newTestProvider(t, fake)
+
+	records := []dns.Record{
+		{Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300},
+		{Name: "WWW.Example.COM", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10},
+	}
+	for _, record := range records {
+		if _, err := p.Create(record); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	want := []recordBody{
+		{ID: "new-1", ZoneID: zoneID, Type: "TXT", Name: "@", Value: `"v=spf1 -all"`, TTL: 300},
+		{ID: "new-2", ZoneID: zoneID, Type: "CNAME", Name: "WWW", Value: "example.com.", TTL: 300},
+		{ID: "new-3", ZoneID: zoneID, Type: "MX", Name: "@", Value: "10 mx.example.com."},
+	}
+	if !reflect.DeepEqual(fake.records, want) {
+		t.Fatalf("records = %+v, want %+v", fake.records, want)
+	}
+
+	updated, err := p.Update(dns.Record{ID: "new-2", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.org", TTL: 60})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	wantUpdated := dns.Record{ID: "new-2", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.org", TTL: 60}
+	if updated != wantUpdated {
+		t.Errorf("Update() = %+v, want %+v", updated, wantUpdated)
+	}
+
+	if err := p.Delete(dns.Record{ID: "new-1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{ID: "new-1"}); err == nil {
+		t.Error("Delete() succeeded for a deleted record")
+	}
+	if len(fake.records) != 2 {
+		t.Errorf("records = %+v, want 2 records", fake.records)
+	}
+}
+
+func TestFetchZoneID(t *testing.T) {
+	server := httptest.NewServer(&fakeHetzner{})
+	t.Cleanup(server.Close)
+
+	id, err := FetchZoneID(server.URL, "secret", "example.com")
+	if err != nil || id != zoneID {
+		t.Errorf("FetchZoneID() = %s, %v, want %s", id, err, zoneID)
+	}
+	if _, err := FetchZoneID(server.URL, "secret", "example.org"); err == nil {
+		t.Error("FetchZoneID() succeeded for an unknown zone")
+	}
+	if _, err := FetchZoneID(server.URL, "wrong", "example.com"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("FetchZoneID() error = %v, want 401", err)
+	}
+}
@@ -0,0 +1,399 @@
+package hostsfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const (
+	FormatHosts   = "hosts"
+	FormatDnsmasq = "dnsmasq"
+
+	blockStart = "# BEGIN dockdns managed block, do not edit"
+	blockEnd   = "# END dockdns managed block"
+)
+
+var capabilities = map[string]dns.Capabilities{
+	FormatHosts: {
+		RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA},
+	},
+	// dnsmasq config files can hold CNAMEs and wildcards as well
+	FormatDnsmasq: {
+		RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME},
+	},
+}
+
+// Several zones may share one file, so the lock is kept per file instead of per provider
+var (
+	locksMu sync.Mutex
+	locks   = map[string]*sync.Mutex{}
+)
+
+func fileLock(path string) *sync.Mutex {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	if _, ok := locks[path]; !ok {
+		locks[path] = &sync.Mutex{}
+	}
+	return locks[path]
+}
+
+type hostsfileProvider struct {
+	path          string
+	format        string
+	zone          string
+	reloadProcess string
+	mu            *sync.Mutex
+}
+
+func New(path, format, zone, reloadProcess string) (hostsfileProvider, error) {
+	if path == "" {
+		return hostsfileProvider{}, fmt.Errorf("no hosts file path set for zone %s", zone)
+	}
+	if format == "" {
+		format = FormatHosts
+	}
+	if format != FormatHosts && format != FormatDnsmasq {
+		return hostsfileProvider{}, fmt.Errorf("invalid hosts file format %s, expected %s or %s", format, FormatHosts, FormatDnsmasq)
+	}
+
+	return hostsfileProvider{
+		path:          path,
+		format:        format,
+		zone:          zone,
+		reloadProcess: reloadProcess,
+		mu:            fileLock(path),
+	}, nil
+}
+
+func (p hostsfileProvider) Capabilities() dns.Capabilities {
+	return capabilities[p.format]
+}
+
+// List returns the records of the zone within the managed block, entries outside of it are never touched
+func (p hostsfileProvider) List() ([]dns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, records, _, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(records, func(r dns.Record) bool { return !p.inZone(r.Name) }), nil
+}
+
+func (p hostsfileProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p hostsfileProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p hostsfileProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p hostsfileProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply rewrites the managed block once for all changes and signals the configured process afterwards
+func (p hostsfileProvider) Apply(changes []dns.Change) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	before, records, after, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		record := change.Record
+		if change.Action != dns.ActionDelete {
+			if err := p.Capabilities().Validate(record); err != nil {
+				return fmt.Errorf("invalid record %s: %w", record.Name, err)
+			}
+			if strings.HasPrefix(record.Name, "*.") && (p.format == FormatHosts || record.Type == constants.RecordTypeCNAME) {
+				return fmt.Errorf("invalid record %s: hosts files do not support wildcard names", record.Name)
+			}
+		}
+
+		sameSet := func(r dns.Record) bool { return strings.EqualFold(r.Name, record.Name) && r.Type == record.Type }
+		sameRecord := func(r dns.Record) bool { return sameSet(r) && r.Content == record.Content }
+
+		switch change.Action {
+		case dns.ActionCreate:
+			if !slices.ContainsFunc(records, sameRecord) {
+				records = append(records, record)
+			}
+		case dns.ActionUpdate:
+			idx := slices.IndexFunc(records, sameSet)
+			records = slices.DeleteFunc(records, sameSet)
+			if idx < 0 {
+				idx = len(records)
+			}
+			records = slices.Insert(records, idx, record)
+		case dns.ActionDelete:
+			records = slices.DeleteFunc(records, sameRecord)
+		}
+	}
+
+	if err := p.write(before, records, after); err != nil {
+		return err
+	}
+	return p.reload()
+}
+
+// read returns the lines before the managed block, the records inside of it and the lines after it
+func (p hostsfileProvider) read() ([]string, []dns.Record, []string, error) {
+	content, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not read hosts file: %w", err)
+	}
+
+	var before, after []string
+	var records []dns.Record
+	inBlock, seenBlock := false, false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == blockStart:
+			inBlock, seenBlock = true, true
+		case strings.TrimSpace(line) == blockEnd:
+			inBlock = false
+		case inBlock:
+			if record, ok := p.parseLine(line); ok {
+				records = append(records, record)
+			}
+		case seenBlock:
+			after = append(after, line)
+		default:
+			before = append(before, line)
+		}
+	}
+	return before, records, after, scanner.Err()
+}
+
+// write replaces the file atomically by writing a temporary file next to it and renaming it.
+// Files bind mounted into a container cannot be replaced, they are rewritten in place instead.
+func (p hostsfileProvider) write(before []string, records []dns.Record, after []string) error {
+	var buf bytes.Buffer
+	for _, line := range before {
+		buf.WriteString(line + "\n")
+	}
+	buf.WriteString(blockStart + "\n")
+	for _, record := range records {
+		buf.WriteString(p.formatLine(record) + "\n")
+	}
+	buf.WriteString(blockEnd + "\n")
+	for _, line := range after {
+		buf.WriteString(line + "\n")
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(p.path); err == nil {
+		mode = info.Mode()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), "."+filepath.Base(p.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary hosts file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(buf.Bytes())
+	err = errors.Join(err, tmp.Chmod(mode), tmp.Sync(), tmp.Close())
+	if err != nil {
+		return fmt.Errorf("could not write hosts file: %w", err)
+	}
+	err = os.Rename(tmp.Name(), p.path)
+	if errors.Is(err, syscall.EBUSY) {
+		slog.Debug("hosts file cannot be replaced, writing it in place", "path", p.path)
+		err = os.WriteFile(p.path, buf.Bytes(), mode)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write hosts file: %w", err)
+	}
+	return nil
+}
+
+func (p hostsfileProvider) formatLine(record dns.Record) string {
+	if p.format == FormatHosts {
+		return record.Content + " " + record.Name
+	}
+
+	switch {
+	case record.Type == constants.RecordTypeCNAME:
+		return "cname=" + record.Name + "," + record.Content
+	case strings.HasPrefix(record.Name, "*."):
+		// address= also answers for all subdomains, which is how dnsmasq serves wildcards
+		return "address=/" + strings.TrimPrefix(record.Name, "*.") + "/" + record.Content
+	default:
+		return "host-record=" + record.Name + "," + record.Content
+	}
+}
+
+// parseLine parses a hosts line or one of the dnsmasq directives written by formatLine
+func (p hostsfileProvider) parseLine(line string) (dns.Record, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return dns.Record{}, false
+	}
+
+	var name, content, recordType string
+	if key, value, found := strings.Cut(line, "="); found {
+		switch key {
+		case "cname":
+			name, content, _ = strings.Cut(value, ",")
+			recordType = constants.RecordTypeCNAME
+		case "host-record":
+			name, content, _ = strings.Cut(value, ",")
+		case "address":
+			parts := strings.Split(strings.Trim(value, "/"), "/")
+			if len(parts) != 2 {
+				return dns.Record{}, false
+			}
+			name, content = "*."+parts[0], parts[1]
+		default:
+			return dns.Record{}, false
+		}
+	} else {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return dns.Record{}, false
+		}
+		name, content = fields[1], fields[0]
+	}
+
+	if recordType == "" {
+		ip := net.ParseIP(content)
+		switch {
+		case ip == nil:
+			return dns.Record{}, false
+		case ip.To4() != nil:
+			recordType = constants.RecordTypeA
+		default:
+			recordType = constants.RecordTypeAAAA
+		}
+	}
+
+	return dns.Record{
+		ID:      dns.RecordID(name, recordType),
+		Name:    name,
+		Type:    recordType,
+		Content: content,
+	}, true
+}
+
+func (p hostsfileProvider) inZone(name string) bool {
+	name, zone := strings.ToLower(name), strings.ToLower(p.zone)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// reload sends SIGHUP to the configured process, which makes dnsmasq re-read its hosts files.
+// Config directives of the dnsmasq format are only read on start, SIGHUP does not reload them.
+// The process can be given as PID, as path to a PID file or as process name.
+func (p hostsfileProvider) reload() error {
+	if p.reloadProcess == "" {
+		return nil
+	}
+
+	pids, err := findProcess(p.reloadProcess)
+	if err != nil {
+		return fmt.Errorf("hosts file was written, but the process to reload could not be found: %w", err)
+	}
+
+	for _, pid := range pids {
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Signal(syscall.SIGHUP)
+		}
+		if err != nil {
+			return fmt.Errorf("hosts file was written, but the process %d could not be signaled: %w", pid, err)
+		}
+		slog.Debug("sent SIGHUP", "pid", pid, "zone", p.zone)
+	}
+	return nil
+}
+
+func findProcess(process string) ([]int, error) {
+	if pid, err := strconv.Atoi(process); err == nil {
+		return []int{pid}, nil
+	}
+
+	if strings.ContainsRune(process, os.PathSeparator) {
+		content, err := os.ReadFile(process)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid file %s: %w", process, err)
+		}
+		return []int{pid}, nil
+	}
+
+	// Look up the process by name
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+		if err == nil && strings.TrimSpace(string(comm)) == process {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process named %s found", process)
+	}
+	return pids, nil
+}
@@ -0,0 +1,131 @@
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+func create(name, recordType, content string) dns.Change {
+	return dns.Change{Action: dns.ActionCreate, Record: dns.Record{Name: name, Type: recordType, Content: content}}
+}
+
+func TestSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	initial := "127.0.0.1 localhost\n" +
+		blockStart + "\n" +
+		"10.0.0.9 old.lab.lan\n" +
+		blockEnd + "\n"
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	home, err := New(path, "", "home.lan", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lab, err := New(path, FormatHosts, "lab.lan", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := home.Apply([]dns.Change{create("app.home.lan", constants.RecordTypeA, "10.0.0.1")}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := lab.Apply([]dns.Change{create("app.lab.lan", constants.RecordTypeAAAA, "fd00::1")}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "127.0.0.1 localhost\n" +
+		blockStart + "\n" +
+		"10.0.0.9 old.lab.lan\n" +
+		"10.0.0.1 app.home.lan\n" +
+		"fd00::1 app.lab.lan\n" +
+		blockEnd + "\n"
+	if string(content) != want {
+		t.Errorf("file content = %q, want %q", content, want)
+	}
+
+	records, err := lab.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := []dns.Record{
+		{ID: "old.lab.lan/A", Name: "old.lab.lan", Type: constants.RecordTypeA, Content: "10.0.0.9"},
+		{ID: "app.lab.lan/AAAA", Name: "app.lab.lan", Type: constants.RecordTypeAAAA, Content: "fd00::1"},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("List() = %+v, want %+v", records, wantRecords)
+	}
+}
+
+func TestHostsFormatRejectsWildcardsAndCNAMEs(t *testing.T) {
+	p, err := New(filepath.Join(t.TempDir(), "hosts"), "", "home.lan", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Apply([]dns.Change{create("*.home.lan", constants.RecordTypeA, "10.0.0.1")}); err == nil {
+		t.Error("Apply() accepted a wildcard name")
+	}
+	if err := p.Apply([]dns.Change{create("www.home.lan", constants.RecordTypeCNAME, "home.lan")}); err == nil {
+		t.Error("Apply() accepted a CNAME")
+	}
+}
+
+func TestDnsmasqFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dockdns.conf")
+	p, err := New(path, FormatDnsmasq, "home.lan", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []dns.Change{
+		create("nas.home.lan", constants.RecordTypeA, "10.0.0.1"),
+		create("nas.home.lan", constants.RecordTypeAAAA, "fd00::1"),
+		create("*.apps.home.lan", constants.RecordTypeA, "10.0.0.2"),
+		create("files.home.lan", constants.RecordTypeCNAME, "nas.home.lan"),
+	}
+	if err := p.Apply(changes); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := blockStart + "\n" +
+		"host-record=nas.home.lan,10.0.0.1\n" +
+		"host-record=nas.home.lan,fd00::1\n" +
+		"address=/apps.home.lan/10.0.0.2\n" +
+		"cname=files.home.lan,nas.home.lan\n" +
+		blockEnd + "\n"
+	if string(content) != want {
+		t.Errorf("file content = %q, want %q", content, want)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantRecords []dns.Record
+	for _, change := range changes {
+		record := change.Record
+		record.ID = dns.RecordID(record.Name, record.Type)
+		wantRecords = append(wantRecords, record)
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("List() = %+v, want %+v", records, wantRecords)
+	}
+
+	if err := p.Apply([]dns.Change{create("*.home.lan", constants.RecordTypeCNAME, "nas.home.lan")}); err == nil {
+		t.Error("Apply() accepted a wildcard CNAME")
+	}
+}
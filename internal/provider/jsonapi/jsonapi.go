@@ -0,0 +1,80 @@
+// Package jsonapi is the HTTP client shared by the providers talking to a plain JSON REST API.
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout bounds every request of the providers talking to an HTTP API, including reading the response
+const Timeout = 30 * time.Second
+
+// NewHTTPClient returns the client for providers that build their requests themselves, e.g. for form encoded APIs
+func NewHTTPClient() *http.Client {
+	return &http.Client{Timeout: Timeout}
+}
+
+// Client sends JSON requests to the paths below the base URL of an API
+type Client struct {
+	name      string
+	baseURL   string
+	authorize func(req *http.Request)
+	http      *http.Client
+}
+
+// New returns a client for the API at baseURL. name is used in error messages, authorize adds the credentials to
+// every request and may be nil.
+func New(name, baseURL string, authorize func(req *http.Request)) Client {
+	return Client{
+		name:      name,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		authorize: authorize,
+		http:      NewHTTPClient(),
+	}
+}
+
+// Do sends the body as JSON and decodes the response into result. Both may be nil.
+// Responses with a non-2xx status fail with the status and the response body.
+func (c Client) Do(method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s api returned %s: %s", c.name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
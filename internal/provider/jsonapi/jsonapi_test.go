@@ -0,0 +1,56 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/echo":
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "missing content type", http.StatusBadRequest)
+				return
+			}
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			body["method"] = r.Method
+			json.NewEncoder(w).Encode(body)
+		case "/api/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := New("test", server.URL+"/api/", func(req *http.Request) { req.Header.Set("X-API-Key", "secret") })
+
+	var result map[string]string
+	if err := client.Do(http.MethodPut, "/echo", map[string]string{"name": "www"}, &result); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if result["name"] != "www" || result["method"] != http.MethodPut {
+		t.Errorf("Do() result = %v, want the echoed body", result)
+	}
+	if err := client.Do(http.MethodDelete, "/empty", nil, &result); err != nil {
+		t.Errorf("Do() error = %v for a response without content", err)
+	}
+
+	err := client.Do(http.MethodGet, "/missing", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "test api returned 404 Not Found") {
+		t.Errorf("Do() error = %v, want the status", err)
+	}
+	err = New("test", server.URL+"/api", nil).Do(http.MethodGet, "/echo", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `{"error":"unauthorized"}`) {
+		t.Errorf("Do() error = %v, want the response body", err)
+	}
+}
@@ -0,0 +1,382 @@
+package namecheap
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const defaultApiURL = "https://api.namecheap.com/xml.response"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type namecheapProvider struct {
+	apiURL   string
+	username string
+	apiKey   string
+	clientIP string
+	zone     string
+	sld      string
+	tld      string
+	client   *http.Client
+	// Number of hosts expected on the next read, used to detect incomplete reads before overwriting the host list
+	expected *expectedHosts
+}
+
+// Number of consecutive writes refused with the same suspicious host count, after which the count is taken as new baseline
+const confirmReads = 3
+
+// expectedHosts holds the baseline of the host count. It is taken from the first non-empty read and raised by larger
+// reads, only writes of dockdns may lower it. Reads below the baseline, and an empty first read, are suspicious: writes are
+// refused until a read reaches the baseline again or the same count was read before confirmReads writes in a row,
+// e.g. because hosts were deleted by hand.
+type expectedHosts struct {
+	mu    sync.Mutex
+	count int
+	known bool
+	// Suspicious count read before the last refused writes and how often it was read in a row
+	pending int
+	repeats int
+}
+
+// check verifies a read of count hosts before a write, the caller has to hold the lock
+func (e *expectedHosts) check(count int, zone string) error {
+	if count >= e.count && (e.known || count > 0) {
+		e.update(count)
+		return nil
+	}
+
+	if count != e.pending {
+		e.pending, e.repeats = count, 0
+	}
+	e.repeats++
+	if e.repeats >= confirmReads {
+		slog.Warn("host count read repeatedly below the expected count, taking it as new baseline", "zone", zone, "hosts", count, "expected", e.count)
+		e.update(count)
+		return nil
+	}
+
+	if !e.known {
+		return fmt.Errorf("read no hosts for %s. Refusing to overwrite the host list until the same count was read %d times in a row", zone, confirmReads)
+	}
+	return fmt.Errorf("read %d hosts for %s, but expected at least %d. Refusing to overwrite the host list until the same count was read %d times in a row",
+		count, zone, e.count, confirmReads)
+}
+
+// update takes count as baseline, e.g. after a complete read or a write of dockdns
+func (e *expectedHosts) update(count int) {
+	e.count, e.known = count, true
+	e.pending, e.repeats = 0, 0
+}
+
+type apiResponse struct {
+	Status string `xml:"Status,attr"`
+	Errors []struct {
+		Number  string `xml:"Number,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"Errors>Error"`
+	GetHosts struct {
+		EmailType string `xml:"EmailType,attr"`
+		Hosts     []host `xml:"host"`
+	} `xml:"CommandResponse>DomainDNSGetHostsResult"`
+	SetHosts struct {
+		IsSuccess bool `xml:"IsSuccess,attr"`
+	} `xml:"CommandResponse>DomainDNSSetHostsResult"`
+}
+
+type host struct {
+	Name    string `xml:"Name,attr"`
+	Type    string `xml:"Type,attr"`
+	Address string `xml:"Address,attr"`
+	MXPref  string `xml:"MXPref,attr"`
+	TTL     int    `xml:"TTL,attr"`
+}
+
+func New(apiURL, username, apiKey, clientIP, zone string) (namecheapProvider, error) {
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+	if username == "" || apiKey == "" {
+		return namecheapProvider{}, fmt.Errorf("username and api key are required for zone %s", zone)
+	}
+	if clientIP == "" {
+		return namecheapProvider{}, fmt.Errorf("no client ip set for zone %s. Namecheap requires the whitelisted ip of the caller", zone)
+	}
+
+	sld, tld, found := strings.Cut(zone, ".")
+	if !found {
+		return namecheapProvider{}, fmt.Errorf("invalid zone name %s, expected a registered domain like example.com", zone)
+	}
+
+	return namecheapProvider{
+		apiURL:   apiURL,
+		username: username,
+		apiKey:   apiKey,
+		clientIP: clientIP,
+		zone:     zone,
+		sld:      sld,
+		tld:      tld,
+		client:   jsonapi.NewHTTPClient(),
+		expected: &expectedHosts{},
+	}, nil
+}
+
+func (p namecheapProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p namecheapProvider) List() ([]dns.Record, error) {
+	p.expected.mu.Lock()
+	defer p.expected.mu.Unlock()
+
+	hosts, _, err := p.getHosts()
+	if err != nil {
+		return nil, err
+	}
+	// Incomplete reads only make the plan incomplete, writes check the host count themselves
+	if len(hosts) > p.expected.count {
+		p.expected.update(len(hosts))
+	}
+
+	var records []dns.Record
+	for _, h := range hosts {
+		if capabilities.Supports(h.Type) {
+			records = append(records, p.mapRecord(h))
+		}
+	}
+	return records, nil
+}
+
+func (p namecheapProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p namecheapProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p namecheapProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p namecheapProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply merges all changes into the current host list and writes it back with a single setHosts call.
+// setHosts replaces all hosts of the domain, so every host not touched by the changes is written back unmodified.
+func (p namecheapProvider) Apply(changes []dns.Change) error {
+	p.expected.mu.Lock()
+	defer p.expected.mu.Unlock()
+
+	hosts, emailType, err := p.getHosts()
+	if err != nil {
+		return err
+	}
+
+	if err := p.expected.check(len(hosts), p.zone); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		record := change.Record
+		h := p.toHost(record)
+		sameSet := func(existing host) bool {
+			return strings.EqualFold(existing.Name, h.Name) && existing.Type == h.Type
+		}
+		sameHost := func(existing host) bool {
+			if record.Type == constants.RecordTypeMX && existing.MXPref != h.MXPref {
+				return false
+			}
+			return sameSet(existing) && strings.EqualFold(strings.TrimSuffix(existing.Address, "."), strings.TrimSuffix(h.Address, "."))
+		}
+
+		switch change.Action {
+		case dns.ActionCreate:
+			if !slices.ContainsFunc(hosts, sameHost) {
+				hosts = append(hosts, h)
+			}
+			// MX hosts are only served with the custom mail setting
+			if record.Type == constants.RecordTypeMX {
+				emailType = "MX"
+			}
+		case dns.ActionUpdate:
+			idx := slices.IndexFunc(hosts, sameSet)
+			hosts = slices.DeleteFunc(hosts, sameSet)
+			if idx < 0 {
+				idx = len(hosts)
+			}
+			hosts = slices.Insert(hosts, idx, h)
+		case dns.ActionDelete:
+			hosts = slices.DeleteFunc(hosts, sameHost)
+		}
+	}
+
+	if err := p.setHosts(hosts, emailType); err != nil {
+		return err
+	}
+	p.expected.update(len(hosts))
+	return nil
+}
+
+func (p namecheapProvider) getHosts() ([]host, string, error) {
+	params := p.params("namecheap.domains.dns.getHosts")
+
+	resp, err := p.call(params)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.GetHosts.Hosts, resp.GetHosts.EmailType, nil
+}
+
+func (p namecheapProvider) setHosts(hosts []host, emailType string) error {
+	params := p.params("namecheap.domains.dns.setHosts")
+	if emailType != "" {
+		params.Set("EmailType", emailType)
+	}
+	for i, h := range hosts {
+		n := strconv.Itoa(i + 1)
+		params.Set("HostName"+n, h.Name)
+		params.Set("RecordType"+n, h.Type)
+		params.Set("Address"+n, h.Address)
+		if h.MXPref != "" {
+			params.Set("MXPref"+n, h.MXPref)
+		}
+		if h.TTL > 0 {
+			params.Set("TTL"+n, strconv.Itoa(h.TTL))
+		}
+	}
+
+	resp, err := p.call(params)
+	if err != nil {
+		return err
+	}
+	if !resp.SetHosts.IsSuccess {
+		return fmt.Errorf("namecheap did not confirm the host update for %s", p.zone)
+	}
+	return nil
+}
+
+func (p namecheapProvider) params(command string) url.Values {
+	return url.Values{
+		"ApiUser":  {p.username},
+		"ApiKey":   {p.apiKey},
+		"UserName": {p.username},
+		"ClientIp": {p.clientIP},
+		"Command":  {command},
+		"SLD":      {p.sld},
+		"TLD":      {p.tld},
+	}
+}
+
+func (p namecheapProvider) call(params url.Values) (apiResponse, error) {
+	// Host lists can get long, send the parameters as form body instead of the query string
+	resp, err := p.client.PostForm(p.apiURL, params)
+	if err != nil {
+		return apiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiResponse{}, fmt.Errorf("namecheap api returned %s for zone %s", resp.Status, p.zone)
+	}
+
+	var result apiResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return apiResponse{}, fmt.Errorf("could not decode namecheap response: %w", err)
+	}
+	if result.Status != "OK" {
+		var errs []error
+		for _, e := range result.Errors {
+			errs = append(errs, fmt.Errorf("%s (%s)", strings.TrimSpace(e.Message), e.Number))
+		}
+		return apiResponse{}, fmt.Errorf("namecheap api call %s failed: %w", params.Get("Command"), errors.Join(errs...))
+	}
+	return result, nil
+}
+
+// Namecheap uses host names relative to the domain, '@' being the apex
+func (p namecheapProvider) toHost(record dns.Record) host {
+	name := dns.RelativeName(record.Name, p.zone)
+	if name == "" {
+		name = "@"
+	}
+
+	address := record.Content
+	var mxPref string
+	switch record.Type {
+	case constants.RecordTypeCNAME:
+		address = strings.TrimSuffix(address, ".") + "."
+	case constants.RecordTypeMX:
+		address = strings.TrimSuffix(address, ".") + "."
+		mxPref = strconv.Itoa(record.Priority)
+	}
+
+	return host{
+		Name:    name,
+		Type:    record.Type,
+		Address: address,
+		MXPref:  mxPref,
+		TTL:     record.TTL,
+	}
+}
+
+func (p namecheapProvider) mapRecord(h host) dns.Record {
+	name := p.zone
+	if h.Name != "@" {
+		name = h.Name + "." + p.zone
+	}
+
+	content := h.Address
+	var priority int
+	switch h.Type {
+	case constants.RecordTypeCNAME:
+		content = strings.TrimSuffix(content, ".")
+	case constants.RecordTypeMX:
+		content = strings.TrimSuffix(content, ".")
+		priority, _ = strconv.Atoi(h.MXPref)
+	}
+
+	// Namecheap reassigns host IDs on every write, so they can't be used as record IDs
+	return dns.Record{
+		ID:       dns.RecordID(name, h.Type),
+		Name:     name,
+		Type:     h.Type,
+		Content:  content,
+		TTL:      h.TTL,
+		Priority: priority,
+	}
+}
@@ -0,0 +1,156 @@
+package namecheap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeNamecheap serves the first hosts of its host list on getHosts and records the hosts written by setHosts
+type fakeNamecheap struct {
+	hosts   []host
+	visible int
+	writes  int
+}
+
+func (f *fakeNamecheap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body strings.Builder
+	switch r.Form.Get("Command") {
+	case "namecheap.domains.dns.getHosts":
+		body.WriteString(`<DomainDNSGetHostsResult EmailType="FWD">`)
+		for _, h := range f.hosts[:f.visible] {
+			fmt.Fprintf(&body, `<host Name="%s" Type="%s" Address="%s" MXPref="10" TTL="%d"/>`, h.Name, h.Type, h.Address, h.TTL)
+		}
+		body.WriteString(`</DomainDNSGetHostsResult>`)
+	case "namecheap.domains.dns.setHosts":
+		f.writes++
+		f.hosts = nil
+		for i := 1; r.Form.Has(fmt.Sprintf("HostName%d", i)); i++ {
+			f.hosts = append(f.hosts, host{
+				Name:    r.Form.Get(fmt.Sprintf("HostName%d", i)),
+				Type:    r.Form.Get(fmt.Sprintf("RecordType%d", i)),
+				Address: r.Form.Get(fmt.Sprintf("Address%d", i)),
+			})
+		}
+		f.visible = len(f.hosts)
+		body.WriteString(`<DomainDNSSetHostsResult IsSuccess="true"/>`)
+	}
+	fmt.Fprintf(w, `<ApiResponse Status="OK"><CommandResponse>%s</CommandResponse></ApiResponse>`, body.String())
+}
+
+func newTestProvider(t *testing.T, fake *fakeNamecheap) namecheapProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "user", "key", "203.0.113.10", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func existingHosts() []host {
+	return []host{
+		{Name: "@", Type: constants.RecordTypeA, Address: "10.0.0.1"},
+		{Name: "www", Type: constants.RecordTypeCNAME, Address: "example.com."},
+		{Name: "@", Type: constants.RecordTypeTXT, Address: "v=spf1 -all"},
+	}
+}
+
+var create = []dns.Change{{Action: dns.ActionCreate, Record: dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.2"}}}
+
+func TestApplyRefusesIncompleteReads(t *testing.T) {
+	fake := &fakeNamecheap{hosts: existingHosts(), visible: 3}
+	p := newTestProvider(t, fake)
+
+	if err := p.Apply(create); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(fake.hosts) != 4 {
+		t.Fatalf("wrote %d hosts, want 4", len(fake.hosts))
+	}
+
+	// Incomplete reads are refused, also on the following runs. Listing still works for planning
+	fake.visible = 2
+	for range confirmReads - 1 {
+		if err := p.Apply(create); err == nil {
+			t.Fatal("Apply() succeeded after an incomplete read")
+		}
+		if _, err := p.List(); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+	}
+	if fake.writes != 1 {
+		t.Fatalf("wrote the host list %d times, want 1", fake.writes)
+	}
+
+	// Writes resume once the read is complete again
+	fake.visible = 4
+	if err := p.Apply(create); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if fake.writes != 2 {
+		t.Fatalf("wrote the host list %d times, want 2", fake.writes)
+	}
+}
+
+func TestApplyRefusesEmptyFirstRead(t *testing.T) {
+	fake := &fakeNamecheap{hosts: existingHosts(), visible: 0}
+	p := newTestProvider(t, fake)
+
+	if err := p.Apply(create); err == nil {
+		t.Fatal("Apply() succeeded after an empty first read")
+	}
+	if fake.writes != 0 {
+		t.Fatalf("wrote the host list %d times, want none", fake.writes)
+	}
+
+	fake.visible = 3
+	if err := p.Apply(create); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(fake.hosts) != 4 {
+		t.Errorf("wrote %d hosts, want the existing hosts and the new one", len(fake.hosts))
+	}
+}
+
+func TestApplyTakesRepeatedCountAsBaseline(t *testing.T) {
+	fake := &fakeNamecheap{hosts: existingHosts(), visible: 3}
+	p := newTestProvider(t, fake)
+	if _, err := p.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A host was deleted by hand
+	fake.hosts = fake.hosts[1:]
+	fake.visible = 2
+	for i := range confirmReads {
+		err := p.Apply(create)
+		if last := i == confirmReads-1; last != (err == nil) {
+			t.Fatalf("Apply() #%d error = %v", i+1, err)
+		}
+	}
+	if fake.writes != 1 || len(fake.hosts) != 3 {
+		t.Errorf("wrote %d times with %d hosts, want one write with 3 hosts", fake.writes, len(fake.hosts))
+	}
+
+	// An empty domain is written after the same confirmation
+	empty := &fakeNamecheap{}
+	p = newTestProvider(t, empty)
+	for range confirmReads {
+		p.Apply(create)
+	}
+	if empty.writes != 1 {
+		t.Errorf("wrote the empty domain %d times, want 1", empty.writes)
+	}
+}
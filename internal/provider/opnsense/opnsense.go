@@ -0,0 +1,325 @@
+package opnsense
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const settingsPath = "/api/unbound/settings"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME},
+	Comment:     true,
+}
+
+type opnsenseProvider struct {
+	client jsonapi.Client
+	zone   string
+}
+
+type hostOverride struct {
+	UUID        string `json:"uuid,omitempty"`
+	Enabled     string `json:"enabled"`
+	Hostname    string `json:"hostname"`
+	Domain      string `json:"domain"`
+	RR          string `json:"rr"`
+	Server      string `json:"server"`
+	Description string `json:"description"`
+}
+
+// hostAlias is an additional name for a host override, which is how CNAMEs are modelled
+type hostAlias struct {
+	UUID        string `json:"uuid,omitempty"`
+	Enabled     string `json:"enabled"`
+	Host        string `json:"host"`
+	Hostname    string `json:"hostname"`
+	Domain      string `json:"domain"`
+	Description string `json:"description"`
+}
+
+type saveResponse struct {
+	Result      string         `json:"result"`
+	UUID        string         `json:"uuid"`
+	Validations map[string]any `json:"validations"`
+}
+
+func New(apiURL, apiKey, apiSecret, zone string) (opnsenseProvider, error) {
+	if apiURL == "" {
+		return opnsenseProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+
+	// All API calls are POST requests, the API key and secret are sent as basic auth credentials
+	return opnsenseProvider{
+		client: jsonapi.New("opnsense", apiURL, func(req *http.Request) {
+			req.SetBasicAuth(apiKey, apiSecret)
+		}),
+		zone: zone,
+	}, nil
+}
+
+func (p opnsenseProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List returns the host overrides and aliases below the zone
+func (p opnsenseProvider) List() ([]dns.Record, error) {
+	hosts, err := p.hosts()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := p.aliases()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, host := range hosts {
+		if record, ok := mapHost(host); ok && p.inZone(record.Name) {
+			records = append(records, record)
+		}
+	}
+	for _, alias := range aliases {
+		if record := mapAlias(alias, hosts); alias.Enabled == "1" && p.inZone(record.Name) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p opnsenseProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p opnsenseProvider) Create(record dns.Record) (dns.Record, error) {
+	return p.applyOne(dns.Change{Action: dns.ActionCreate, Record: record})
+}
+
+func (p opnsenseProvider) Update(record dns.Record) (dns.Record, error) {
+	return p.applyOne(dns.Change{Action: dns.ActionUpdate, Record: record})
+}
+
+func (p opnsenseProvider) Delete(record dns.Record) error {
+	_, err := p.applyOne(dns.Change{Action: dns.ActionDelete, Record: record})
+	return err
+}
+
+func (p opnsenseProvider) applyOne(change dns.Change) (dns.Record, error) {
+	record, err := p.apply(change)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	return record, p.reconfigure()
+}
+
+// Apply saves all changes and reconfigures Unbound once afterwards.
+// A failed change doesn't stop the remaining ones, the errors of all failed changes are returned together.
+func (p opnsenseProvider) Apply(changes []dns.Change) error {
+	var errs []error
+	for _, change := range changes {
+		if _, err := p.apply(change); err != nil {
+			errs = append(errs, fmt.Errorf("could not %s %s record %s: %w", change.Action, change.Record.Type, change.Record.Name, err))
+		}
+	}
+
+	if err := p.reconfigure(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// apply saves a single change, without making it active
+func (p opnsenseProvider) apply(change dns.Change) (dns.Record, error) {
+	record := change.Record
+	if !p.inZone(record.Name) {
+		return dns.Record{}, fmt.Errorf("%s is not part of zone %s", record.Name, p.zone)
+	}
+	if change.Action != dns.ActionDelete {
+		if err := capabilities.Validate(record); err != nil {
+			return dns.Record{}, fmt.Errorf("invalid record %s: %w", record.Name, err)
+		}
+	}
+
+	kind := "HostOverride"
+	if record.Type == constants.RecordTypeCNAME {
+		kind = "HostAlias"
+	}
+
+	var body any
+	var err error
+	if change.Action != dns.ActionDelete {
+		if body, err = p.toEntry(record); err != nil {
+			return dns.Record{}, err
+		}
+	}
+
+	switch change.Action {
+	case dns.ActionCreate:
+		record.ID, err = p.save("add"+kind, body)
+	case dns.ActionUpdate:
+		if record.ID == "" {
+			var existing dns.Record
+			if existing, err = p.Get(record.Name, record.Type); err != nil {
+				return dns.Record{}, err
+			}
+			record.ID = existing.ID
+		}
+		if record.ID == "" {
+			record.ID, err = p.save("add"+kind, body)
+		} else {
+			_, err = p.save("set"+kind+"/"+url.PathEscape(record.ID), body)
+		}
+	case dns.ActionDelete:
+		if record.ID == "" {
+			var existing dns.Record
+			if existing, err = p.Get(record.Name, record.Type); err != nil || existing.ID == "" {
+				return dns.Record{}, err
+			}
+			record.ID = existing.ID
+		}
+		err = p.client.Do(http.MethodPost, settingsPath+"/del"+kind+"/"+url.PathEscape(record.ID), struct{}{}, nil)
+	}
+	if err != nil {
+		return dns.Record{}, err
+	}
+	return record, nil
+}
+
+// toEntry returns the request body to save the record as host override or alias
+func (p opnsenseProvider) toEntry(record dns.Record) (any, error) {
+	hostname, domain, _ := strings.Cut(record.Name, ".")
+
+	if record.Type != constants.RecordTypeCNAME {
+		return map[string]hostOverride{"host": {
+			Enabled:     "1",
+			Hostname:    hostname,
+			Domain:      domain,
+			RR:          record.Type,
+			Server:      record.Content,
+			Description: record.Comment,
+		}}, nil
+	}
+
+	// Aliases point to a host override, so the CNAME target has to be an existing host override
+	hosts, err := p.hosts()
+	if err != nil {
+		return nil, err
+	}
+	idx := slices.IndexFunc(hosts, func(h hostOverride) bool {
+		return strings.EqualFold(hostName(h.Hostname, h.Domain), strings.TrimSuffix(record.Content, "."))
+	})
+	if idx < 0 {
+		return nil, fmt.Errorf("cname target %s of %s must be an existing host override", record.Content, record.Name)
+	}
+	return map[string]hostAlias{"alias": {
+		Enabled:     "1",
+		Host:        hosts[idx].UUID,
+		Hostname:    hostname,
+		Domain:      domain,
+		Description: record.Comment,
+	}}, nil
+}
+
+func (p opnsenseProvider) hosts() ([]hostOverride, error) {
+	var result struct {
+		Rows []hostOverride `json:"rows"`
+	}
+	err := p.client.Do(http.MethodPost, settingsPath+"/searchHostOverride", searchRequest(), &result)
+	return result.Rows, err
+}
+
+func (p opnsenseProvider) aliases() ([]hostAlias, error) {
+	var result struct {
+		Rows []hostAlias `json:"rows"`
+	}
+	err := p.client.Do(http.MethodPost, settingsPath+"/searchHostAlias", searchRequest(), &result)
+	return result.Rows, err
+}
+
+func (p opnsenseProvider) save(action string, body any) (string, error) {
+	var result saveResponse
+	if err := p.client.Do(http.MethodPost, settingsPath+"/"+action, body, &result); err != nil {
+		return "", err
+	}
+	if result.Result != "saved" {
+		return "", fmt.Errorf("opnsense could not save entry: %s %v", result.Result, result.Validations)
+	}
+	return result.UUID, nil
+}
+
+func (p opnsenseProvider) reconfigure() error {
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := p.client.Do(http.MethodPost, "/api/unbound/service/reconfigure", struct{}{}, &result); err != nil {
+		return fmt.Errorf("could not reconfigure unbound: %w", err)
+	}
+	if !strings.EqualFold(result.Status, "ok") {
+		return fmt.Errorf("could not reconfigure unbound: status %s", result.Status)
+	}
+	return nil
+}
+
+func (p opnsenseProvider) inZone(name string) bool {
+	return strings.EqualFold(name, p.zone) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(p.zone))
+}
+
+func searchRequest() map[string]any {
+	return map[string]any{"current": 1, "rowCount": -1, "searchPhrase": ""}
+}
+
+func mapHost(host hostOverride) (dns.Record, bool) {
+	if host.Enabled != "1" || (host.RR != constants.RecordTypeA && host.RR != constants.RecordTypeAAAA) {
+		return dns.Record{}, false
+	}
+
+	return dns.Record{
+		ID:      host.UUID,
+		Name:    hostName(host.Hostname, host.Domain),
+		Type:    host.RR,
+		Content: host.Server,
+		Comment: host.Description,
+	}, true
+}
+
+// mapAlias maps an alias to a CNAME record pointing to the name of its host override
+func mapAlias(alias hostAlias, hosts []hostOverride) dns.Record {
+	// Depending on the version, search results contain the UUID or the name of the host override
+	target := alias.Host
+	if idx := slices.IndexFunc(hosts, func(h hostOverride) bool { return h.UUID == alias.Host }); idx >= 0 {
+		target = hostName(hosts[idx].Hostname, hosts[idx].Domain)
+	}
+
+	return dns.Record{
+		ID:      alias.UUID,
+		Name:    hostName(alias.Hostname, alias.Domain),
+		Type:    constants.RecordTypeCNAME,
+		Content: target,
+		Comment: alias.Description,
+	}
+}
+
+func hostName(hostname, domain string) string {
+	if hostname == "" {
+		return domain
+	}
+	return hostname + "." + domain
+}
@@ -0,0 +1,96 @@
+package opnsense
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeOPNsense serves the Unbound host overrides and aliases. Like OPNsense, it refuses hostnames with
+// invalid characters.
+type fakeOPNsense struct {
+	hosts        []hostOverride
+	aliases      []hostAlias
+	nextID       int
+	reconfigured int
+}
+
+func (f *fakeOPNsense) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, settingsPath+"/"), "/")
+
+	switch action {
+	case "searchHostOverride":
+		json.NewEncoder(w).Encode(map[string]any{"rows": f.hosts})
+	case "searchHostAlias":
+		json.NewEncoder(w).Encode(map[string]any{"rows": f.aliases})
+	case "addHostOverride":
+		var body map[string]hostOverride
+		json.NewDecoder(r.Body).Decode(&body)
+		host := body["host"]
+		if strings.Contains(host.Hostname, "_") {
+			json.NewEncoder(w).Encode(saveResponse{Result: "failed", Validations: map[string]any{"host.hostname": "invalid"}})
+			return
+		}
+		f.nextID++
+		host.UUID = fmt.Sprintf("uuid-%d", f.nextID)
+		f.hosts = append(f.hosts, host)
+		json.NewEncoder(w).Encode(saveResponse{Result: "saved", UUID: host.UUID})
+	case "delHostOverride":
+		f.hosts = slices.DeleteFunc(f.hosts, func(h hostOverride) bool { return h.UUID == id })
+		json.NewEncoder(w).Encode(map[string]string{"result": "deleted"})
+	default:
+		if r.URL.Path == "/api/unbound/service/reconfigure" {
+			f.reconfigured++
+			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
+
+func TestApplyContinuesAfterFailures(t *testing.T) {
+	fake := &fakeOPNsense{hosts: []hostOverride{
+		{UUID: "uuid-old", Enabled: "1", Hostname: "old", Domain: "home.lan", RR: "A", Server: "192.168.1.9"},
+	}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	p, err := New(server.URL, "key", "secret", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "in_valid.home.lan", Type: constants.RecordTypeA, Content: "192.168.1.2"}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "192.168.1.10"}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "www.home.lan", Type: constants.RecordTypeCNAME, Content: "missing.home.lan"}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "old.home.lan", Type: constants.RecordTypeA}},
+	})
+	if err == nil {
+		t.Fatal("Apply() succeeded with invalid changes")
+	}
+	for _, name := range []string{"in_valid.home.lan", "www.home.lan"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Apply() error = %v, want failure of %s", err, name)
+		}
+	}
+	if fake.reconfigured != 1 {
+		t.Errorf("reconfigured %d times, want once", fake.reconfigured)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dns.Record{{ID: "uuid-1", Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "192.168.1.10"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+}
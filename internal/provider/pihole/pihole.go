@@ -0,0 +1,360 @@
+package pihole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const (
+	hostsPath = "/api/config/dns/hosts"
+	cnamePath = "/api/config/dns/cnameRecords"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME},
+}
+
+type piholeProvider struct {
+	apiURL   string
+	password string
+	zone     string
+	client   *http.Client
+	session  *session
+}
+
+// session is shared between all copies of the provider. Pi-hole only allows a limited number of sessions,
+// so the session is reused until it expires.
+type session struct {
+	mu  sync.Mutex
+	sid string
+}
+
+func New(apiURL, password, zone string) (piholeProvider, error) {
+	if apiURL == "" {
+		return piholeProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+
+	return piholeProvider{
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		password: password,
+		zone:     zone,
+		client:   jsonapi.NewHTTPClient(),
+		session:  &session{},
+	}, nil
+}
+
+func (p piholeProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List returns the local DNS and CNAME records below the zone. Entries for other domains are never returned
+func (p piholeProvider) List() ([]dns.Record, error) {
+	hosts, err := p.entries(hostsPath, "hosts")
+	if err != nil {
+		return nil, err
+	}
+	cnames, err := p.entries(cnamePath, "cnameRecords")
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, entry := range hosts {
+		for _, record := range parseHost(entry) {
+			if p.inZone(record.Name) {
+				records = append(records, record)
+			}
+		}
+	}
+	for _, entry := range cnames {
+		if record, ok := parseCNAME(entry); ok && p.inZone(record.Name) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p piholeProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p piholeProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	var path, entry string
+	if record.Type == constants.RecordTypeCNAME {
+		path, entry = cnamePath, record.Name+","+record.Content
+	} else {
+		path, entry = hostsPath, record.Content+" "+record.Name
+	}
+
+	if err := p.do(http.MethodPut, path+"/"+url.PathEscape(entry), nil); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p piholeProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	// Entries can't be modified, remove the existing entries for the name and add a new one
+	if err := p.remove(record.Name, record.Type, ""); err != nil {
+		return dns.Record{}, err
+	}
+	return p.Create(record)
+}
+
+func (p piholeProvider) Delete(record dns.Record) error {
+	if !p.inZone(record.Name) {
+		return fmt.Errorf("refusing to delete %s, it is not part of zone %s", record.Name, p.zone)
+	}
+	return p.remove(record.Name, record.Type, record.Content)
+}
+
+// remove deletes the name from all matching entries. If content is empty, entries with any content are matched
+func (p piholeProvider) remove(name, recordType, content string) error {
+	if recordType == constants.RecordTypeCNAME {
+		cnames, err := p.entries(cnamePath, "cnameRecords")
+		if err != nil {
+			return err
+		}
+		for _, entry := range cnames {
+			record, ok := parseCNAME(entry)
+			if !ok || !strings.EqualFold(record.Name, name) || (content != "" && !strings.EqualFold(record.Content, content)) {
+				continue
+			}
+			if err := p.do(http.MethodDelete, cnamePath+"/"+url.PathEscape(entry), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	hosts, err := p.entries(hostsPath, "hosts")
+	if err != nil {
+		return err
+	}
+	for _, entry := range hosts {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 || ipType(fields[0]) != recordType || (content != "" && fields[0] != content) {
+			continue
+		}
+
+		remaining := slices.DeleteFunc(slices.Clone(fields[1:]), func(host string) bool { return strings.EqualFold(host, name) })
+		if len(remaining) == len(fields)-1 {
+			continue
+		}
+
+		if err := p.do(http.MethodDelete, hostsPath+"/"+url.PathEscape(entry), nil); err != nil {
+			return err
+		}
+		// An entry can hold several host names, keep the ones not being deleted
+		if len(remaining) > 0 {
+			newEntry := fields[0] + " " + strings.Join(remaining, " ")
+			if err := p.do(http.MethodPut, hostsPath+"/"+url.PathEscape(newEntry), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p piholeProvider) validate(record dns.Record) error {
+	if err := capabilities.Validate(record); err != nil {
+		return fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+	if !p.inZone(record.Name) {
+		return fmt.Errorf("%s is not part of zone %s", record.Name, p.zone)
+	}
+	return nil
+}
+
+func (p piholeProvider) inZone(name string) bool {
+	return strings.EqualFold(name, p.zone) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(p.zone))
+}
+
+func (p piholeProvider) entries(path, key string) ([]string, error) {
+	var result struct {
+		Config struct {
+			DNS map[string][]string `json:"dns"`
+		} `json:"config"`
+	}
+	if err := p.do(http.MethodGet, path, &result); err != nil {
+		return nil, err
+	}
+	return result.Config.DNS[key], nil
+}
+
+func (p piholeProvider) do(method, path string, result any) error {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+
+	resp, err := p.send(method, path)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && p.password != "" {
+		// Session expired, login again and retry once
+		resp.Body.Close()
+		p.session.sid = ""
+		resp, err = p.send(method, path)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(resp)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (p piholeProvider) send(method, path string) (*http.Response, error) {
+	if p.session.sid == "" && p.password != "" {
+		if err := p.login(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, p.apiURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.session.sid != "" {
+		req.Header.Set("X-FTL-SID", p.session.sid)
+	}
+	return p.client.Do(req)
+}
+
+func (p piholeProvider) login() error {
+	body, err := json.Marshal(map[string]string{"password": p.password})
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Post(p.apiURL+"/api/auth", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pi-hole login failed: %w", apiError(resp))
+	}
+
+	var auth struct {
+		Session struct {
+			Valid bool   `json:"valid"`
+			SID   string `json:"sid"`
+		} `json:"session"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return err
+	}
+	if !auth.Session.Valid {
+		return errors.New("pi-hole login failed: invalid session")
+	}
+	p.session.sid = auth.Session.SID
+	return nil
+}
+
+func apiError(resp *http.Response) error {
+	var apiErr struct {
+		Error struct {
+			Key     string `json:"key"`
+			Message string `json:"message"`
+			Hint    string `json:"hint"`
+		} `json:"error"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Errorf("pi-hole api returned %s: %s %s", resp.Status, apiErr.Error.Message, apiErr.Error.Hint)
+	}
+	return fmt.Errorf("pi-hole api returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// parseHost parses a local DNS entry like '192.168.1.10 host.lan other.lan'
+func parseHost(entry string) []dns.Record {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 {
+		return nil
+	}
+
+	recordType := ipType(fields[0])
+	if recordType == "" {
+		return nil
+	}
+
+	var records []dns.Record
+	for _, host := range fields[1:] {
+		records = append(records, dns.Record{
+			ID:      dns.RecordID(host, recordType),
+			Name:    host,
+			Type:    recordType,
+			Content: fields[0],
+		})
+	}
+	return records
+}
+
+// parseCNAME parses a local CNAME entry like 'alias.lan,target.lan[,ttl]'
+func parseCNAME(entry string) (dns.Record, bool) {
+	parts := strings.Split(entry, ",")
+	if len(parts) < 2 {
+		return dns.Record{}, false
+	}
+
+	record := dns.Record{
+		ID:      dns.RecordID(parts[0], constants.RecordTypeCNAME),
+		Name:    parts[0],
+		Type:    constants.RecordTypeCNAME,
+		Content: parts[1],
+	}
+	if len(parts) > 2 {
+		record.TTL, _ = strconv.Atoi(parts[2])
+	}
+	return record, true
+}
+
+func ipType(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return constants.RecordTypeA
+	default:
+		return constants.RecordTypeAAAA
+	}
+}
@@ -0,0 +1,199 @@
+package pihole
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakePihole serves the local DNS and CNAME entries of the v6 API. Every login starts a new session
+type fakePihole struct {
+	hosts  []string
+	cnames []string
+	logins int
+	sid    string
+}
+
+func (f *fakePihole) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/auth" {
+		var body struct {
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"session": map[string]any{"valid": false}})
+			return
+		}
+		f.logins++
+		f.sid = fmt.Sprintf("sid-%d", f.logins)
+		json.NewEncoder(w).Encode(map[string]any{"session": map[string]any{"valid": true, "sid": f.sid}})
+		return
+	}
+	if f.sid == "" || r.Header.Get("X-FTL-SID") != f.sid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"key": "unauthorized", "message": "Unauthorized"}})
+		return
+	}
+
+	var entries *[]string
+	var key, path string
+	switch {
+	case strings.HasPrefix(r.URL.Path, hostsPath):
+		entries, key, path = &f.hosts, "hosts", hostsPath
+	case strings.HasPrefix(r.URL.Path, cnamePath):
+		entries, key, path = &f.cnames, "cnameRecords", cnamePath
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	entry := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, path), "/")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]any{"config": map[string]any{"dns": map[string]any{key: *entries}}})
+	case http.MethodPut:
+		if slices.Contains(*entries, entry) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"key": "bad_request", "message": "Item already present", "hint": entry}})
+			return
+		}
+		*entries = append(*entries, entry)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		idx := slices.Index(*entries, entry)
+		if idx < 0 {
+			http.NotFound(w, r)
+			return
+		}
+		*entries = slices.Delete(*entries, idx, idx+1)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakePihole) piholeProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "secret", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	fake := &fakePihole{
+		hosts:  []string{"10.0.0.1 nas.home.lan files.home.lan", "fd00::1 nas.home.lan", "10.0.0.9 router.other.lan"},
+		cnames: []string{"media.home.lan,nas.home.lan,300", "www.other.lan,router.other.lan"},
+	}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	// Entries of other domains are never returned
+	want := []dns.Record{
+		{ID: "nas.home.lan/A", Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.1"},
+		{ID: "files.home.lan/A", Name: "files.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.1"},
+		{ID: "nas.home.lan/AAAA", Name: "nas.home.lan", Type: constants.RecordTypeAAAA, Content: "fd00::1"},
+		{ID: "media.home.lan/CNAME", Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "nas.home.lan", TTL: 300},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	record, err := p.Get("NAS.home.lan", constants.RecordTypeAAAA)
+	if err != nil || record != want[2] {
+		t.Errorf("Get() = %+v, %v, want %+v", record, err, want[2])
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	fake := &fakePihole{hosts: []string{"10.0.0.1 nas.home.lan files.home.lan"}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Create(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := p.Create(dns.Record{Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "nas.home.lan"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Updating a name shared with another host name keeps the other one
+	if _, err := p.Update(dns.Record{Name: "files.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.3"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := p.Update(dns.Record{Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "app.home.lan"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	wantHosts := []string{"10.0.0.1 nas.home.lan", "10.0.0.3 files.home.lan"}
+	if !reflect.DeepEqual(fake.hosts, wantHosts) {
+		t.Errorf("hosts = %q, want %q", fake.hosts, wantHosts)
+	}
+	wantCNAMEs := []string{"media.home.lan,app.home.lan"}
+	if !reflect.DeepEqual(fake.cnames, wantCNAMEs) {
+		t.Errorf("cnames = %q, want %q", fake.cnames, wantCNAMEs)
+	}
+}
+
+func TestRejectsRecordsOutsideZone(t *testing.T) {
+	fake := &fakePihole{hosts: []string{"10.0.0.9 router.other.lan"}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Create(dns.Record{Name: "app.other.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err == nil {
+		t.Error("Create() accepted a record outside of the zone")
+	}
+	if err := p.Delete(dns.Record{Name: "router.other.lan", Type: constants.RecordTypeA, Content: "10.0.0.9"}); err == nil {
+		t.Error("Delete() accepted a record outside of the zone")
+	}
+	if _, err := p.Create(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 60}); err == nil {
+		t.Error("Create() accepted a custom TTL")
+	}
+	if len(fake.hosts) != 1 {
+		t.Errorf("hosts = %q, want them unchanged", fake.hosts)
+	}
+}
+
+func TestSession(t *testing.T) {
+	fake := &fakePihole{}
+	p := newTestProvider(t, fake)
+
+	// The session is reused across calls and copies of the provider
+	for range 3 {
+		if _, err := p.List(); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+	}
+	if fake.logins != 1 {
+		t.Errorf("logged in %d times, want 1", fake.logins)
+	}
+
+	// An expired session is renewed once
+	fake.sid = "expired"
+	if _, err := p.List(); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if fake.logins != 2 {
+		t.Errorf("logged in %d times, want 2", fake.logins)
+	}
+
+	p.password = "wrong"
+	p.session.sid = ""
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "pi-hole login failed") {
+		t.Errorf("List() error = %v, want a failed login", err)
+	}
+}
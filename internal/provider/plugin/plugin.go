@@ -0,0 +1,267 @@
+// Package plugin runs DNS providers maintained outside of dockdns as separate processes.
+//
+// dockdns starts the plugin binary and talks JSON-RPC 2.0 to it, one JSON object per line on stdin and stdout.
+// Stderr of the plugin is passed through. The first call is always 'handshake', followed by any of the
+// dns.Provider operations 'list', 'get', 'create', 'update' and 'delete'.
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// ProtocolVersion is increased on incompatible changes of the protocol
+const ProtocolVersion = 1
+
+const Prefix = "plugin:"
+
+// A plugin not answering within the timeout is killed and started again on the next call
+const defaultTimeout = 30 * time.Second
+
+type pluginProvider struct {
+	client *client
+}
+
+type handshakeRequest struct {
+	ProtocolVersion int               `json:"protocolVersion"`
+	Zone            string            `json:"zone"`
+	Options         map[string]string `json:"options"`
+}
+
+type handshakeResponse struct {
+	ProtocolVersion int `json:"protocolVersion"`
+	Capabilities    struct {
+		// An empty list means all record types are supported
+		RecordTypes []string `json:"recordTypes"`
+		Proxied     bool     `json:"proxied"`
+		Comment     bool     `json:"comment"`
+		TTL         bool     `json:"ttl"`
+	} `json:"capabilities"`
+}
+
+type getRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func New(path, zone string, options map[string]string) (pluginProvider, error) {
+	if path == "" {
+		return pluginProvider{}, fmt.Errorf("no plugin path set for zone %s", zone)
+	}
+
+	c := &client{
+		path:      path,
+		handshake: handshakeRequest{ProtocolVersion: ProtocolVersion, Zone: zone, Options: options},
+		timeout:   defaultTimeout,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.start(); err != nil {
+		return pluginProvider{}, err
+	}
+	return pluginProvider{client: c}, nil
+}
+
+// Capabilities returns the capabilities of the latest handshake, a restarted plugin may announce different ones
+func (p pluginProvider) Capabilities() dns.Capabilities {
+	return p.client.capabilities()
+}
+
+func (p pluginProvider) List() ([]dns.Record, error) {
+	var records []dns.Record
+	err := p.client.call("list", struct{}{}, &records)
+	return records, err
+}
+
+func (p pluginProvider) Get(name, recordType string) (dns.Record, error) {
+	var record dns.Record
+	err := p.client.call("get", getRequest{Name: name, Type: recordType}, &record)
+	return record, err
+}
+
+func (p pluginProvider) Create(record dns.Record) (dns.Record, error) {
+	result := record
+	if err := p.client.call("create", record, &result); err != nil {
+		return dns.Record{}, err
+	}
+	return result, nil
+}
+
+func (p pluginProvider) Update(record dns.Record) (dns.Record, error) {
+	result := record
+	if err := p.client.call("update", record, &result); err != nil {
+		return dns.Record{}, err
+	}
+	return result, nil
+}
+
+func (p pluginProvider) Delete(record dns.Record) error {
+	return p.client.call("delete", record, nil)
+}
+
+// client owns the plugin process. Calls are serialized, if the process dies it is restarted on the next call.
+type client struct {
+	path      string
+	handshake handshakeRequest
+	timeout   time.Duration
+
+	mu                sync.Mutex
+	cmd               *exec.Cmd
+	stdin             io.WriteCloser
+	stdoutFile        *os.File
+	stdout            *bufio.Reader
+	nextID            int
+	handshakeResponse handshakeResponse
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+func (c *client) call(method string, params, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil {
+		slog.Info("restarting plugin", "path", c.path, "zone", c.handshake.Zone)
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+
+	err := c.send(method, params, result)
+	var rpcErr *rpcError
+	if err != nil && !errors.As(err, &rpcErr) {
+		// The process is in an unknown state, start it again on the next call
+		c.stop()
+	}
+	return err
+}
+
+func (c *client) capabilities() dns.Capabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	caps := c.handshakeResponse.Capabilities
+	return dns.Capabilities{
+		RecordTypes: caps.RecordTypes,
+		Proxied:     caps.Proxied,
+		Comment:     caps.Comment,
+		TTL:         caps.TTL,
+	}
+}
+
+// start launches the plugin process and performs the handshake
+func (c *client) start() error {
+	cmd := exec.Command(c.path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// The pipe is created here instead of using StdoutPipe, so reads can be given a deadline
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = stdoutWriter
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return fmt.Errorf("could not start plugin %s: %w", c.path, err)
+	}
+	c.cmd, c.stdin, c.stdoutFile, c.stdout = cmd, stdin, stdout, bufio.NewReader(stdout)
+
+	var resp handshakeResponse
+	if err := c.send("handshake", c.handshake, &resp); err != nil {
+		c.stop()
+		return fmt.Errorf("handshake with plugin %s failed: %w", c.path, err)
+	}
+	if resp.ProtocolVersion != ProtocolVersion {
+		c.stop()
+		return fmt.Errorf("plugin %s speaks protocol version %d, expected %d", c.path, resp.ProtocolVersion, ProtocolVersion)
+	}
+	c.handshakeResponse = resp
+	return nil
+}
+
+func (c *client) stop() {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.stdoutFile.Close()
+	c.cmd = nil
+}
+
+func (c *client) send(method string, params, result any) error {
+	c.nextID++
+	request, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: c.nextID, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if _, err := c.stdin.Write(append(request, '\n')); err != nil {
+		return fmt.Errorf("could not write to plugin %s: %w", c.path, err)
+	}
+	if err := c.stdoutFile.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := c.stdout.ReadBytes('\n')
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("plugin %s did not answer %s within %s", c.path, method, c.timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read from plugin %s: %w", c.path, err)
+		}
+
+		var resp rpcResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return fmt.Errorf("invalid response from plugin %s: %w", c.path, err)
+		}
+		if resp.ID != c.nextID {
+			slog.Debug("ignoring plugin response with unexpected id", "path", c.path, "id", resp.ID)
+			continue
+		}
+
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
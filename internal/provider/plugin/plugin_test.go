@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// The test binary doubles as plugin. The record types announced in the handshake are read from the file
+// named by DOCKDNS_TEST_PLUGIN on every start, 'list' never answers.
+func TestMain(m *testing.M) {
+	if path := os.Getenv("DOCKDNS_TEST_PLUGIN"); path != "" {
+		runPlugin(path)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runPlugin(path string) {
+	content, _ := os.ReadFile(path)
+	recordTypes := strings.Fields(string(content))
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(1)
+		}
+
+		switch req.Method {
+		case "handshake":
+			var resp handshakeResponse
+			resp.ProtocolVersion = ProtocolVersion
+			resp.Capabilities.RecordTypes = recordTypes
+			resp.Capabilities.TTL = true
+			result, _ := json.Marshal(resp)
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":%s}`+"\n", req.ID, result)
+		case "list":
+			select {}
+		default:
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":null}`+"\n", req.ID)
+		}
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	capsFile := t.TempDir() + "/record-types"
+	if err := os.WriteFile(capsFile, []byte("A AAAA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKDNS_TEST_PLUGIN", capsFile)
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(executable, "example.com", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	p.client.timeout = 100 * time.Millisecond
+	defer func() {
+		p.client.mu.Lock()
+		p.client.stop()
+		p.client.mu.Unlock()
+	}()
+
+	if p.Capabilities().Supports(constants.RecordTypeTXT) {
+		t.Error("TXT records supported, but the plugin only announced A and AAAA")
+	}
+
+	// A plugin that does not answer is killed
+	start := time.Now()
+	if _, err := p.List(); err == nil {
+		t.Fatal("List() succeeded, but the plugin never answers")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("List() returned after %s, expected the timeout to apply", time.Since(start))
+	}
+
+	// The restarted plugin announces all record types with an empty list
+	if err := os.WriteFile(capsFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Delete(dns.Record{Name: "www.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if !p.Capabilities().Supports(constants.RecordTypeTXT) {
+		t.Error("TXT records not supported after the plugin announced all record types")
+	}
+}
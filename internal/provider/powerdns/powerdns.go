@@ -0,0 +1,246 @@
+package powerdns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const defaultServerID = "localhost"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	Comment:     true,
+	TTL:         true,
+}
+
+type powerdnsProvider struct {
+	client   jsonapi.Client
+	serverID string
+	zone     string
+}
+
+type zone struct {
+	RRsets []rrset `json:"rrsets"`
+}
+
+type rrset struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	TTL        int       `json:"ttl,omitempty"`
+	ChangeType string    `json:"changetype,omitempty"`
+	Records    []record  `json:"records"`
+	Comments   []comment `json:"comments"`
+}
+
+type record struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type comment struct {
+	Content string `json:"content"`
+	Account string `json:"account"`
+}
+
+func New(apiURL, serverID, apiToken, zone string) (powerdnsProvider, error) {
+	if apiURL == "" {
+		return powerdnsProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+	if serverID == "" {
+		serverID = defaultServerID
+	}
+
+	return powerdnsProvider{
+		client: jsonapi.New("powerdns", apiURL, func(req *http.Request) {
+			req.Header.Set("X-API-Key", apiToken)
+		}),
+		serverID: serverID,
+		zone:     canonical(zone),
+	}, nil
+}
+
+func (p powerdnsProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p powerdnsProvider) List() ([]dns.Record, error) {
+	rrsets, err := p.rrsets()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, set := range rrsets {
+		if !capabilities.Supports(set.Type) {
+			continue
+		}
+		records = append(records, mapRecords(set)...)
+	}
+	return records, nil
+}
+
+func (p powerdnsProvider) Get(domain, recordType string) (dns.Record, error) {
+	set, err := p.rrset(domain, recordType)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	records := mapRecords(set)
+	if len(records) == 0 {
+		return dns.Record{}, nil
+	}
+	return records[0], nil
+}
+
+func (p powerdnsProvider) Create(r dns.Record) (dns.Record, error) {
+	// PowerDNS only manages whole RRsets. Add the new record to the existing set, so other values are kept
+	set, err := p.rrset(r.Name, r.Type)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	content := dns.PresentationValue(r)
+	if !slices.ContainsFunc(set.Records, func(existing record) bool { return existing.Content == content }) {
+		set.Records = append(set.Records, record{Content: content})
+	}
+
+	if err := p.replace(r, set.Records); err != nil {
+		return dns.Record{}, err
+	}
+	return withID(r), nil
+}
+
+func (p powerdnsProvider) Update(r dns.Record) (dns.Record, error) {
+	if err := p.replace(r, []record{{Content: dns.PresentationValue(r)}}); err != nil {
+		return dns.Record{}, err
+	}
+	return withID(r), nil
+}
+
+func (p powerdnsProvider) Delete(r dns.Record) error {
+	set, err := p.rrset(r.Name, r.Type)
+	if err != nil {
+		return err
+	}
+
+	content := dns.PresentationValue(r)
+	remaining := slices.DeleteFunc(set.Records, func(existing record) bool { return existing.Content == content })
+	if len(remaining) > 0 {
+		r.TTL = set.TTL
+		if len(set.Comments) > 0 {
+			r.Comment = set.Comments[0].Content
+		}
+		return p.replace(r, remaining)
+	}
+
+	return p.patch(rrset{
+		Name:       canonical(r.Name),
+		Type:       r.Type,
+		ChangeType: "DELETE",
+		Records:    []record{},
+		Comments:   []comment{},
+	})
+}
+
+func (p powerdnsProvider) replace(r dns.Record, records []record) error {
+	comments := []comment{}
+	if r.Comment != "" {
+		comments = append(comments, comment{Content: r.Comment})
+	}
+
+	return p.patch(rrset{
+		Name:       canonical(r.Name),
+		Type:       r.Type,
+		TTL:        r.TTL,
+		ChangeType: "REPLACE",
+		Records:    records,
+		Comments:   comments,
+	})
+}
+
+func (p powerdnsProvider) rrsets() ([]rrset, error) {
+	var z zone
+	if err := p.do(http.MethodGet, nil, &z); err != nil {
+		return nil, err
+	}
+	return z.RRsets, nil
+}
+
+func (p powerdnsProvider) rrset(domain, recordType string) (rrset, error) {
+	rrsets, err := p.rrsets()
+	if err != nil {
+		return rrset{}, err
+	}
+
+	for _, set := range rrsets {
+		if strings.EqualFold(set.Name, canonical(domain)) && set.Type == recordType {
+			return set, nil
+		}
+	}
+	return rrset{Name: canonical(domain), Type: recordType}, nil
+}
+
+func (p powerdnsProvider) patch(set rrset) error {
+	return p.do(http.MethodPatch, zone{RRsets: []rrset{set}}, nil)
+}
+
+func (p powerdnsProvider) do(method string, body, result any) error {
+	path := fmt.Sprintf("/api/v1/servers/%s/zones/%s", url.PathEscape(p.serverID), url.PathEscape(p.zone))
+	if err := p.client.Do(method, path, body, result); err != nil {
+		return fmt.Errorf("zone %s: %w", p.zone, err)
+	}
+	return nil
+}
+
+func mapRecords(set rrset) []dns.Record {
+	var records []dns.Record
+
+	name := strings.TrimSuffix(set.Name, ".")
+	var recordComment string
+	if len(set.Comments) > 0 {
+		recordComment = set.Comments[0].Content
+	}
+
+	for _, r := range set.Records {
+		if r.Disabled {
+			continue
+		}
+		content := r.Content
+		var priority int
+		switch set.Type {
+		case constants.RecordTypeCNAME:
+			content = strings.TrimSuffix(content, ".")
+		case constants.RecordTypeTXT:
+			content = dns.UnquoteTXT(content)
+		case constants.RecordTypeMX:
+			priority, content = dns.ParseMX(content)
+		}
+		records = append(records, dns.Record{
+			ID:       dns.RecordID(name, set.Type),
+			Name:     name,
+			Type:     set.Type,
+			Content:  content,
+			TTL:      set.TTL,
+			Comment:  recordComment,
+			Priority: priority,
+		})
+	}
+
+	return records
+}
+
+func withID(r dns.Record) dns.Record {
+	r.ID = dns.RecordID(r.Name, r.Type)
+	return r
+}
+
+func canonical(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
@@ -0,0 +1,151 @@
+package powerdns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakePowerDNS serves the zone example.com and applies the RRset patches like PowerDNS does
+type fakePowerDNS struct {
+	rrsets  []rrset
+	patches []rrset
+}
+
+func (f *fakePowerDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-API-Key") != "secret" {
+		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+		http.Error(w, `{"error":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(zone{RRsets: f.rrsets})
+	case http.MethodPatch:
+		var body zone
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, set := range body.RRsets {
+			f.patches = append(f.patches, set)
+			f.rrsets = slices.DeleteFunc(f.rrsets, func(existing rrset) bool { return existing.Name == set.Name && existing.Type == set.Type })
+			if set.ChangeType == "REPLACE" {
+				set.ChangeType = ""
+				f.rrsets = append(f.rrsets, set)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakePowerDNS) powerdnsProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "", "secret", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	fake := &fakePowerDNS{rrsets: []rrset{
+		{Name: "example.com.", Type: "SOA", TTL: 3600, Records: []record{{Content: "ns1.example.com. admin.example.com. 1 10800 3600 604800 3600"}}},
+		{Name: "example.com.", Type: "TXT", TTL: 300, Records: []record{{Content: `"v=spf1 -all"`}}},
+		{Name: "example.com.", Type: "MX", TTL: 300, Records: []record{{Content: "10 mx.example.com."}}},
+		{Name: "www.example.com.", Type: "CNAME", TTL: 60, Records: []record{{Content: "example.com."}}, Comments: []comment{{Content: "dockdns"}}},
+		{Name: "app.example.com.", Type: "A", TTL: 300, Records: []record{{Content: "10.0.0.1"}, {Content: "10.0.0.2", Disabled: true}}},
+	}}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dns.Record{
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300},
+		{ID: "example.com/MX", Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 300, Priority: 10},
+		{ID: "www.example.com/CNAME", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 60, Comment: "dockdns"},
+		{ID: "app.example.com/A", Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+}
+
+func TestCreateMergesIntoRRset(t *testing.T) {
+	fake := &fakePowerDNS{rrsets: []rrset{
+		{Name: "example.com.", Type: "TXT", TTL: 300, Records: []record{{Content: `"v=spf1 -all"`}}},
+	}}
+	p := newTestProvider(t, fake)
+
+	created, err := p.Create(dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=1", TTL: 300, Comment: "dockdns"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID != "example.com/TXT" {
+		t.Errorf("Create() ID = %s, want example.com/TXT", created.ID)
+	}
+	// Creating an existing value keeps the set as it is
+	if _, err := p.Create(dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=1", TTL: 300, Comment: "dockdns"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	merged := rrset{Name: "example.com.", Type: "TXT", TTL: 300, ChangeType: "REPLACE", Records: []record{{Content: `"v=spf1 -all"`}, {Content: `"token=1"`}}, Comments: []comment{{Content: "dockdns"}}}
+	if want := []rrset{merged, merged}; !reflect.DeepEqual(fake.patches, want) {
+		t.Errorf("sent %+v, want %+v", fake.patches, want)
+	}
+}
+
+func TestUpdateReplacesRRset(t *testing.T) {
+	fake := &fakePowerDNS{rrsets: []rrset{
+		{Name: "app.example.com.", Type: "A", TTL: 300, Records: []record{{Content: "10.0.0.1"}, {Content: "10.0.0.2"}}},
+	}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Update(dns.Record{Name: "app.example.com", Type: constants.RecordTypeA, Content: "10.0.0.3", TTL: 60}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	want := []rrset{{Name: "app.example.com.", Type: "A", TTL: 60, ChangeType: "REPLACE", Records: []record{{Content: "10.0.0.3"}}, Comments: []comment{}}}
+	if !reflect.DeepEqual(fake.patches, want) {
+		t.Errorf("sent %+v, want %+v", fake.patches, want)
+	}
+}
+
+func TestDeleteKeepsOtherValues(t *testing.T) {
+	fake := &fakePowerDNS{rrsets: []rrset{
+		{Name: "mail.example.com.", Type: "MX", TTL: 3600, Records: []record{{Content: "10 mx1.example.com."}, {Content: "20 mx2.example.com."}}, Comments: []comment{{Content: "mail"}}},
+	}}
+	p := newTestProvider(t, fake)
+
+	if err := p.Delete(dns.Record{Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx1.example.com", Priority: 10}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "mail.example.com", Type: constants.RecordTypeMX, Content: "mx2.example.com", Priority: 20}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	want := []rrset{
+		// The remaining value keeps the TTL and comment of the set
+		{Name: "mail.example.com.", Type: "MX", TTL: 3600, ChangeType: "REPLACE", Records: []record{{Content: "20 mx2.example.com."}}, Comments: []comment{{Content: "mail"}}},
+		{Name: "mail.example.com.", Type: "MX", ChangeType: "DELETE", Records: []record{}, Comments: []comment{}},
+	}
+	if !reflect.DeepEqual(fake.patches, want) {
+		t.Errorf("sent %+v, want %+v", fake.patches, want)
+	}
+	if len(fake.rrsets) != 0 {
+		t.Errorf("rrsets = %+v, want none", fake.rrsets)
+	}
+}
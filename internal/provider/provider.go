@@ -4,14 +4,54 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"strings"
 
 	"github.com/Tarow/dockdns/internal/config"
 	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/adguardhome"
+	"github.com/Tarow/dockdns/internal/provider/azure"
 	"github.com/Tarow/dockdns/internal/provider/cloudflare"
+	"github.com/Tarow/dockdns/internal/provider/desec"
+	"github.com/Tarow/dockdns/internal/provider/duckdns"
+	"github.com/Tarow/dockdns/internal/provider/etcd"
+	"github.com/Tarow/dockdns/internal/provider/external"
+	"github.com/Tarow/dockdns/internal/provider/gcloud"
+	"github.com/Tarow/dockdns/internal/provider/hetzner"
+	"github.com/Tarow/dockdns/internal/provider/hostsfile"
+	"github.com/Tarow/dockdns/internal/provider/namecheap"
+	"github.com/Tarow/dockdns/internal/provider/opnsense"
+	"github.com/Tarow/dockdns/internal/provider/pihole"
+	"github.com/Tarow/dockdns/internal/provider/plugin"
+	"github.com/Tarow/dockdns/internal/provider/powerdns"
+	"github.com/Tarow/dockdns/internal/provider/rfc2136"
+	"github.com/Tarow/dockdns/internal/provider/route53"
+	"github.com/Tarow/dockdns/internal/provider/routeros"
+	"github.com/Tarow/dockdns/internal/provider/technitium"
+	"github.com/Tarow/dockdns/internal/provider/zonefile"
 )
 
 const (
 	Cloudflare = "cloudflare"
+	RFC2136    = "rfc2136"
+	PowerDNS   = "powerdns"
+	Hetzner    = "hetzner"
+	Route53    = "route53"
+	DeSEC      = "desec"
+	Namecheap  = "namecheap"
+	DuckDNS    = "duckdns"
+	PiHole     = "pihole"
+	AdGuard    = "adguardhome"
+	Technitium = "technitium"
+	ZoneFile   = "zonefile"
+	HostsFile  = "hostsfile"
+	Etcd       = "etcd"
+	Exec       = "exec"
+	HTTP       = "http"
+	GCloud     = "gcloud"
+	Azure      = "azure"
+	RouterOS   = "routeros"
+	OPNsense   = "opnsense"
 )
 
 type ProviderCreator func(config.Zone) (dns.Provider, error)
@@ -20,7 +60,7 @@ var providers = map[string]func(*config.Zone) (dns.Provider, error){
 	Cloudflare: func(zoneCfg *config.Zone) (dns.Provider, error) {
 		if zoneCfg.ZoneID == "" {
 			slog.Debug("zone id not set. Trying to fetch it dynamically", "zone", zoneCfg.Name)
-			zoneID, err := cloudflare.FetchZoneID(zoneCfg.ApiToken, zoneCfg.Name)
+			zoneID, err := cloudflare.FetchZoneID(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.Name)
 			if err != nil {
 				return nil, fmt.Errorf("no zone id set for domain %s and could not fetch it: %w", zoneCfg.Name, err)
 			}
@@ -28,8 +68,115 @@ var providers = map[string]func(*config.Zone) (dns.Provider, error){
 			zoneCfg.ZoneID = zoneID
 		}
 
-		return cloudflare.New(zoneCfg.ApiToken, zoneCfg.ZoneID)
+		return cloudflare.New(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.ZoneID, zoneCfg.AccountID)
 	},
+	RFC2136: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return rfc2136.New(zoneCfg.Nameserver, zoneCfg.Name, zoneCfg.TSIGKeyName, zoneCfg.TSIGSecret, zoneCfg.TSIGAlgorithm)
+	},
+	PowerDNS: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return powerdns.New(zoneCfg.ApiURL, zoneCfg.ServerID, zoneCfg.ApiToken, zoneCfg.Name)
+	},
+	Hetzner: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		if zoneCfg.ZoneID == "" {
+			slog.Debug("zone id not set. Trying to fetch it dynamically", "zone", zoneCfg.Name)
+			zoneID, err := hetzner.FetchZoneID(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.Name)
+			if err != nil {
+				return nil, fmt.Errorf("no zone id set for domain %s and could not fetch it: %w", zoneCfg.Name, err)
+			}
+			slog.Debug("Fetched zone id", "domain", zoneCfg.Name, "zoneID", zoneID)
+			zoneCfg.ZoneID = zoneID
+		}
+
+		return hetzner.New(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.ZoneID, zoneCfg.Name)
+	},
+	Route53: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		if zoneCfg.ZoneID == "" {
+			slog.Debug("hosted zone id not set. Trying to fetch it dynamically", "zone", zoneCfg.Name)
+			zoneID, err := route53.FetchZoneID(zoneCfg.ApiURL, zoneCfg.Name)
+			if err != nil {
+				return nil, fmt.Errorf("no hosted zone id set for domain %s and could not fetch it: %w", zoneCfg.Name, err)
+			}
+			slog.Debug("Fetched hosted zone id", "domain", zoneCfg.Name, "zoneID", zoneID)
+			zoneCfg.ZoneID = zoneID
+		}
+
+		return route53.New(zoneCfg.ApiURL, zoneCfg.ZoneID)
+	},
+	DeSEC: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return desec.New(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.Name)
+	},
+	Namecheap: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return namecheap.New(zoneCfg.ApiURL, zoneCfg.Username, zoneCfg.ApiToken, zoneCfg.ClientIP, zoneCfg.Name)
+	},
+	DuckDNS: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return duckdns.New(zoneCfg.ApiURL, zoneCfg.ApiToken)
+	},
+	PiHole: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return pihole.New(zoneCfg.ApiURL, zoneCfg.Password, zoneCfg.Name)
+	},
+	AdGuard: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return adguardhome.New(zoneCfg.ApiURL, zoneCfg.Username, zoneCfg.Password, zoneCfg.Name)
+	},
+	Technitium: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return technitium.New(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.Name)
+	},
+	ZoneFile: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return zonefile.New(zoneCfg.Path, zoneCfg.Name, zoneCfg.ReloadCommand)
+	},
+	HostsFile: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return hostsfile.New(zoneCfg.Path, zoneCfg.Format, zoneCfg.Name, zoneCfg.ReloadProcess)
+	},
+	Etcd: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return etcd.New(zoneCfg.Endpoints, zoneCfg.Username, zoneCfg.Password, zoneCfg.Prefix, zoneCfg.Name)
+	},
+	Exec: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return external.NewExec(zoneCfg.Command, zoneCfg.Name, capabilities(zoneCfg.Capabilities))
+	},
+	HTTP: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return external.NewHTTP(zoneCfg.ApiURL, zoneCfg.ApiToken, zoneCfg.Name, capabilities(zoneCfg.Capabilities))
+	},
+	GCloud: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		if zoneCfg.ZoneID == "" {
+			slog.Debug("managed zone not set. Trying to fetch it dynamically", "zone", zoneCfg.Name)
+			managedZone, err := gcloud.FetchManagedZone(zoneCfg.ApiURL, zoneCfg.CredentialsFile, zoneCfg.Project, zoneCfg.Name)
+			if err != nil {
+				return nil, fmt.Errorf("no managed zone set for domain %s and could not fetch it: %w", zoneCfg.Name, err)
+			}
+			slog.Debug("Fetched managed zone", "domain", zoneCfg.Name, "managedZone", managedZone)
+			zoneCfg.ZoneID = managedZone
+		}
+
+		return gcloud.New(zoneCfg.ApiURL, zoneCfg.CredentialsFile, zoneCfg.Project, zoneCfg.ZoneID)
+	},
+	Azure: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return azure.New(zoneCfg.ApiURL, zoneCfg.AuthURL, zoneCfg.TenantID, zoneCfg.ClientID, zoneCfg.ClientSecret,
+			zoneCfg.SubscriptionID, zoneCfg.ResourceGroup, zoneCfg.Name)
+	},
+	RouterOS: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return routeros.New(zoneCfg.ApiURL, zoneCfg.Username, zoneCfg.Password, zoneCfg.Name)
+	},
+	OPNsense: func(zoneCfg *config.Zone) (dns.Provider, error) {
+		return opnsense.New(zoneCfg.ApiURL, zoneCfg.Username, zoneCfg.Password, zoneCfg.Name)
+	},
+}
+
+// capabilities converts the configured capabilities of a custom backend. Unset fields keep the provider default
+func capabilities(cfg *config.Capabilities) *dns.Capabilities {
+	if cfg == nil {
+		return nil
+	}
+	capabilities := external.DefaultCapabilities
+	capabilities.RecordTypes = cfg.RecordTypes
+	if cfg.Proxied != nil {
+		capabilities.Proxied = *cfg.Proxied
+	}
+	if cfg.Comment != nil {
+		capabilities.Comment = *cfg.Comment
+	}
+	if cfg.TTL != nil {
+		capabilities.TTL = *cfg.TTL
+	}
+	return &capabilities
 }
 
 func Get(zoneCfg *config.Zone, dryRun bool) (dns.Provider, error) {
@@ -38,6 +185,11 @@ func Get(zoneCfg *config.Zone, dryRun bool) (dns.Provider, error) {
 	}
 
 	providerCreator, exists := providers[zoneCfg.Provider]
+	if path, isPlugin := strings.CutPrefix(zoneCfg.Provider, plugin.Prefix); isPlugin {
+		providerCreator, exists = func(zoneCfg *config.Zone) (dns.Provider, error) {
+			return plugin.New(path, zoneCfg.Name, zoneCfg.Options)
+		}, true
+	}
 	if !exists {
 		return nil, fmt.Errorf("invalid provider: %s", zoneCfg.Provider)
 	}
@@ -48,3 +200,46 @@ func Get(zoneCfg *config.Zone, dryRun bool) (dns.Provider, error) {
 	}
 	return provider, err
 }
+
+// GetBackends creates the provider of the zone and of every backend the zone is mirrored to
+func GetBackends(zoneCfg *config.Zone, dryRun bool) ([]dns.Backend, error) {
+	if err := validateLanIPs(zoneCfg); err != nil {
+		return nil, err
+	}
+	provider, err := Get(zoneCfg, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	backends := []dns.Backend{{Name: zoneCfg.Provider, Provider: provider, IP4: zoneCfg.LanIP4, IP6: zoneCfg.LanIP6}}
+
+	for i := range zoneCfg.Backends {
+		backendCfg := zoneCfg.Backends[i]
+		backendCfg.Name = zoneCfg.Name
+
+		provider, err := Get(&backendCfg, dryRun)
+		if err == nil {
+			err = validateLanIPs(&backendCfg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("backend %d (%s): %w", i+1, backendCfg.Provider, err)
+		}
+		backends = append(backends, dns.Backend{
+			Name:     backendCfg.Provider,
+			Provider: provider,
+			Mirror:   true,
+			IP4:      backendCfg.LanIP4,
+			IP6:      backendCfg.LanIP6,
+		})
+	}
+	return backends, nil
+}
+
+func validateLanIPs(zoneCfg *config.Zone) error {
+	if ip := net.ParseIP(zoneCfg.LanIP4); zoneCfg.LanIP4 != "" && (ip == nil || ip.To4() == nil) {
+		return fmt.Errorf("invalid lanIP4 %s for zone %s", zoneCfg.LanIP4, zoneCfg.Name)
+	}
+	if ip := net.ParseIP(zoneCfg.LanIP6); zoneCfg.LanIP6 != "" && (ip == nil || ip.To4() != nil) {
+		return fmt.Errorf("invalid lanIP6 %s for zone %s", zoneCfg.LanIP6, zoneCfg.Name)
+	}
+	return nil
+}
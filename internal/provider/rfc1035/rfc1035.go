@@ -0,0 +1,53 @@
+// Package rfc1035 converts records from and to resource records in the presentation format of RFC 1035,
+// shared by the providers talking DNS to a server and the ones writing zone files.
+package rfc1035
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/dns"
+	mdns "github.com/miekg/dns"
+)
+
+// NewRR builds the resource record of the record
+func NewRR(record dns.Record) (mdns.RR, error) {
+	rr, err := mdns.NewRR(fmt.Sprintf("%s %d IN %s %s", mdns.Fqdn(record.Name), record.TTL, record.Type, dns.PresentationValue(record)))
+	if err != nil {
+		return nil, fmt.Errorf("could not build resource record for %s: %w", record.Name, err)
+	}
+	return rr, nil
+}
+
+// MapRecord converts a resource record, records of unsupported types are reported as not ok
+func MapRecord(rr mdns.RR) (dns.Record, bool) {
+	var content string
+	var priority int
+	switch v := rr.(type) {
+	case *mdns.A:
+		content = v.A.String()
+	case *mdns.AAAA:
+		content = v.AAAA.String()
+	case *mdns.CNAME:
+		content = strings.TrimSuffix(v.Target, ".")
+	case *mdns.TXT:
+		// The strings are kept escaped, as in presentation format
+		content = dns.UnquoteTXT(`"` + strings.Join(v.Txt, `" "`) + `"`)
+	case *mdns.MX:
+		content = strings.TrimSuffix(v.Mx, ".")
+		priority = int(v.Preference)
+	default:
+		return dns.Record{}, false
+	}
+
+	name := strings.TrimSuffix(rr.Header().Name, ".")
+	recordType := mdns.TypeToString[rr.Header().Rrtype]
+	return dns.Record{
+		ID:       dns.RecordID(name, recordType),
+		Name:     name,
+		Type:     recordType,
+		Content:  content,
+		TTL:      int(rr.Header().Ttl),
+		Priority: priority,
+	}, true
+}
@@ -0,0 +1,185 @@
+package rfc2136
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/rfc1035"
+	mdns "github.com/miekg/dns"
+)
+
+const defaultPort = "53"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type rfc2136Provider struct {
+	nameserver    string
+	zone          string
+	tsigKeyName   string
+	tsigAlgorithm string
+	client        *mdns.Client
+}
+
+func New(nameserver, zone, tsigKeyName, tsigSecret, tsigAlgorithm string) (rfc2136Provider, error) {
+	if nameserver == "" {
+		return rfc2136Provider{}, fmt.Errorf("no nameserver set for zone %s", zone)
+	}
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, defaultPort)
+	}
+
+	if tsigAlgorithm == "" {
+		tsigAlgorithm = mdns.HmacSHA256
+	}
+
+	client := &mdns.Client{}
+	if tsigKeyName != "" {
+		if tsigSecret == "" {
+			return rfc2136Provider{}, fmt.Errorf("tsig key %s set for zone %s, but no tsig secret", tsigKeyName, zone)
+		}
+		client.TsigSecret = map[string]string{mdns.Fqdn(tsigKeyName): tsigSecret}
+	}
+
+	return rfc2136Provider{
+		nameserver:    nameserver,
+		zone:          mdns.Fqdn(zone),
+		tsigKeyName:   mdns.Fqdn(tsigKeyName),
+		tsigAlgorithm: mdns.Fqdn(tsigAlgorithm),
+		client:        client,
+	}, nil
+}
+
+func (p rfc2136Provider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p rfc2136Provider) List() ([]dns.Record, error) {
+	msg := new(mdns.Msg)
+	msg.SetAxfr(p.zone)
+	p.sign(msg)
+
+	transfer := &mdns.Transfer{TsigSecret: p.client.TsigSecret}
+	envelopes, err := transfer.In(msg, p.nameserver)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("zone transfer of %s failed: %w", p.zone, envelope.Error)
+		}
+		for _, rr := range envelope.RR {
+			if record, ok := rfc1035.MapRecord(rr); ok {
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func (p rfc2136Provider) Get(domain, recordType string) (dns.Record, error) {
+	qtype, ok := mdns.StringToType[recordType]
+	if !ok {
+		return dns.Record{}, fmt.Errorf("unsupported record type: %s", recordType)
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetQuestion(mdns.Fqdn(domain), qtype)
+	p.sign(msg)
+
+	resp, _, err := p.client.Exchange(msg, p.nameserver)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	if resp.Rcode != mdns.RcodeSuccess && resp.Rcode != mdns.RcodeNameError {
+		return dns.Record{}, fmt.Errorf("query for %s failed: %s", domain, mdns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		// Only consider answers for the requested name, e.g. skip the target of a CNAME chain
+		if !strings.EqualFold(rr.Header().Name, mdns.Fqdn(domain)) || rr.Header().Rrtype != qtype {
+			continue
+		}
+		if record, ok := rfc1035.MapRecord(rr); ok {
+			return record, nil
+		}
+	}
+	return dns.Record{}, nil
+}
+
+func (p rfc2136Provider) Create(record dns.Record) (dns.Record, error) {
+	rr, err := rfc1035.NewRR(record)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetUpdate(p.zone)
+	msg.Insert([]mdns.RR{rr})
+
+	if err := p.update(msg); err != nil {
+		return dns.Record{}, err
+	}
+	created, _ := rfc1035.MapRecord(rr)
+	return created, nil
+}
+
+func (p rfc2136Provider) Update(record dns.Record) (dns.Record, error) {
+	rr, err := rfc1035.NewRR(record)
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	// DNS UPDATE has no notion of modifying a record, replace the whole RRset within one message instead
+	msg := new(mdns.Msg)
+	msg.SetUpdate(p.zone)
+	msg.RemoveRRset([]mdns.RR{rr})
+	msg.Insert([]mdns.RR{rr})
+
+	if err := p.update(msg); err != nil {
+		return dns.Record{}, err
+	}
+	updated, _ := rfc1035.MapRecord(rr)
+	return updated, nil
+}
+
+func (p rfc2136Provider) Delete(record dns.Record) error {
+	rr, err := rfc1035.NewRR(record)
+	if err != nil {
+		return err
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetUpdate(p.zone)
+	msg.Remove([]mdns.RR{rr})
+
+	return p.update(msg)
+}
+
+func (p rfc2136Provider) update(msg *mdns.Msg) error {
+	p.sign(msg)
+
+	resp, _, err := p.client.Exchange(msg, p.nameserver)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != mdns.RcodeSuccess {
+		return fmt.Errorf("dns update in zone %s failed: %s", p.zone, mdns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+func (p rfc2136Provider) sign(msg *mdns.Msg) {
+	if p.client.TsigSecret != nil {
+		msg.SetTsig(p.tsigKeyName, p.tsigAlgorithm, 300, time.Now().Unix())
+	}
+}
@@ -0,0 +1,173 @@
+package rfc2136
+
+import (
+	"net"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	mdns "github.com/miekg/dns"
+)
+
+const (
+	zone       = "example.com."
+	keyName    = "dockdns."
+	tsigSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+// testServer is an authoritative server for a single zone, accepting TSIG signed queries, transfers and updates
+type testServer struct {
+	mu  sync.Mutex
+	rrs []mdns.RR
+}
+
+func (s *testServer) ServeDNS(w mdns.ResponseWriter, req *mdns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := new(mdns.Msg)
+	resp.SetReply(req)
+	defer func() {
+		if req.IsTsig() != nil {
+			resp.SetTsig(keyName, mdns.HmacSHA256, 300, int64(req.IsTsig().TimeSigned))
+		}
+		w.WriteMsg(resp)
+	}()
+
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		resp.Rcode = mdns.RcodeRefused
+		return
+	}
+
+	if req.Opcode == mdns.OpcodeUpdate {
+		s.update(req.Ns)
+		return
+	}
+
+	question := req.Question[0]
+	if question.Qtype == mdns.TypeAXFR {
+		soa, _ := mdns.NewRR(zone + " 3600 IN SOA ns.example.com. admin.example.com. 1 3600 600 86400 300")
+		resp.Answer = append(append([]mdns.RR{soa}, s.rrs...), soa)
+		return
+	}
+	for _, rr := range s.rrs {
+		if rr.Header().Name == question.Name && rr.Header().Rrtype == question.Qtype {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+}
+
+// update applies the update section of a DNS UPDATE message as described in RFC 2136, section 2.5
+func (s *testServer) update(rrs []mdns.RR) {
+	for _, rr := range rrs {
+		header := rr.Header()
+		sameSet := func(existing mdns.RR) bool {
+			return existing.Header().Name == header.Name && existing.Header().Rrtype == header.Rrtype
+		}
+
+		switch header.Class {
+		case mdns.ClassANY:
+			s.rrs = slices.DeleteFunc(s.rrs, sameSet)
+		case mdns.ClassNONE:
+			header.Class = mdns.ClassINET
+			s.rrs = slices.DeleteFunc(s.rrs, func(existing mdns.RR) bool { return sameSet(existing) && mdns.IsDuplicate(existing, rr) })
+		default:
+			if !slices.ContainsFunc(s.rrs, func(existing mdns.RR) bool { return mdns.IsDuplicate(existing, rr) }) {
+				s.rrs = append(s.rrs, rr)
+			}
+		}
+	}
+}
+
+// startServer serves the zone on UDP and TCP of the same port and returns the address
+func startServer(t *testing.T, handler mdns.Handler) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := map[string]string{keyName: tsigSecret}
+	servers := []*mdns.Server{
+		{Listener: listener, Handler: handler, TsigSecret: secret},
+		{PacketConn: conn, Handler: handler, TsigSecret: secret},
+	}
+	for _, server := range servers {
+		// The default accepts no DNS UPDATE messages
+		server.MsgAcceptFunc = func(mdns.Header) mdns.MsgAcceptAction { return mdns.MsgAccept }
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	return listener.Addr().String()
+}
+
+func TestProvider(t *testing.T) {
+	server := &testServer{}
+	p, err := New(startServer(t, server), "example.com", keyName, tsigSecret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []dns.Record{
+		{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300},
+		{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeTXT, Content: `v=spf1 "quoted" -all`, TTL: 300},
+		{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10, TTL: 300},
+	}
+	for _, record := range records {
+		if _, err := p.Create(record); err != nil {
+			t.Fatalf("Create(%+v) error = %v", record, err)
+		}
+	}
+
+	listed, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for i := range records {
+		records[i].ID = records[i].Name + "/" + records[i].Type
+	}
+	if !reflect.DeepEqual(listed, records) {
+		t.Errorf("List() = %+v, want %+v", listed, records)
+	}
+
+	updated := dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 60}
+	if _, err := p.Update(updated); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := p.Get("example.com", constants.RecordTypeA)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	updated.ID = "example.com/A"
+	if got != updated {
+		t.Errorf("Get() = %+v, want %+v", got, updated)
+	}
+
+	if err := p.Delete(records[2]); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	got, err = p.Get("example.com", constants.RecordTypeTXT)
+	if err != nil || got != (dns.Record{}) {
+		t.Errorf("Get() = %+v, %v after deletion, want no record", got, err)
+	}
+}
+
+func TestUnsignedUpdatesAreRefused(t *testing.T) {
+	p, err := New(startServer(t, &testServer{}), "example.com", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Create(dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300}); err == nil {
+		t.Error("Create() succeeded without TSIG key")
+	}
+}
@@ -0,0 +1,259 @@
+package route53
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	r53 "github.com/aws/aws-sdk-go-v2/service/route53"
+	r53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Route 53 is a global service, the region is only used for signing requests
+const defaultRegion = "us-east-1"
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type route53Provider struct {
+	zoneID string
+	client *r53.Client
+}
+
+func New(apiURL, zoneID string) (route53Provider, error) {
+	client, err := newClient(apiURL)
+	if err != nil {
+		return route53Provider{}, err
+	}
+
+	return route53Provider{
+		zoneID: zoneID,
+		client: client,
+	}, nil
+}
+
+func (p route53Provider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func FetchZoneID(apiURL, domain string) (string, error) {
+	client, err := newClient(apiURL)
+	if err != nil {
+		return "", err
+	}
+
+	zones, err := client.ListHostedZonesByName(context.Background(), &r53.ListHostedZonesByNameInput{
+		DNSName: aws.String(domain),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range zones.HostedZones {
+		if strings.TrimSuffix(aws.ToString(zone.Name), ".") == domain {
+			return strings.TrimPrefix(aws.ToString(zone.Id), "/hostedzone/"), nil
+		}
+	}
+	return "", fmt.Errorf("no hosted zone found for domain %s", domain)
+}
+
+func newClient(apiURL string) (*r53.Client, error) {
+	// Credentials are resolved by the default chain, i.e. environment variables, shared config and profiles
+	cfg, err := awsConfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("could not load aws configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+
+	return r53.NewFromConfig(cfg, func(o *r53.Options) {
+		if apiURL != "" {
+			o.BaseEndpoint = aws.String(apiURL)
+		}
+	}), nil
+}
+
+func (p route53Provider) List() ([]dns.Record, error) {
+	var records []dns.Record
+
+	pages := r53.NewListResourceRecordSetsPaginator(p.client, &r53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.zoneID),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, set := range page.ResourceRecordSets {
+			if !capabilities.Supports(string(set.Type)) {
+				continue
+			}
+			records = append(records, mapRecords(set)...)
+		}
+	}
+
+	return records, nil
+}
+
+func (p route53Provider) Get(domain, recordType string) (dns.Record, error) {
+	set, err := p.recordSet(domain, recordType)
+	if err != nil || set == nil {
+		return dns.Record{}, err
+	}
+
+	records := mapRecords(*set)
+	if len(records) == 0 {
+		return dns.Record{}, nil
+	}
+	return records[0], nil
+}
+
+func (p route53Provider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p route53Provider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p route53Provider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply sends all changes in a single change batch, which Route 53 applies atomically.
+// Route 53 manages whole record sets, so all changes to the same name and type are merged into one set.
+func (p route53Provider) Apply(changes []dns.Change) error {
+	// Deletions must match the existing record set exactly, so the sets are kept as read
+	existingSets := map[string]*r53Types.ResourceRecordSet{}
+	fetch := func(name, recordType string) (*dns.RRSet, error) {
+		existing, err := p.recordSet(name, recordType)
+		if err != nil || existing == nil {
+			return nil, err
+		}
+		existingSets[dns.RecordID(name, recordType)] = existing
+
+		set := &dns.RRSet{Name: name, Type: recordType, TTL: int(aws.ToInt64(existing.TTL))}
+		for _, r := range existing.ResourceRecords {
+			set.Values = append(set.Values, aws.ToString(r.Value))
+		}
+		return set, nil
+	}
+
+	sets, err := dns.MergeRRSets(changes, fetch, dns.PresentationValue)
+	if err != nil {
+		return err
+	}
+
+	var batch []r53Types.Change
+	for _, set := range sets {
+		desired := set.Desired
+		if len(desired.Values) == 0 {
+			batch = append(batch, r53Types.Change{Action: r53Types.ChangeActionDelete, ResourceRecordSet: existingSets[dns.RecordID(desired.Name, desired.Type)]})
+			continue
+		}
+
+		recordSet := &r53Types.ResourceRecordSet{
+			Name: aws.String(desired.Name),
+			Type: r53Types.RRType(desired.Type),
+			TTL:  aws.Int64(int64(desired.TTL)),
+		}
+		for _, value := range desired.Values {
+			recordSet.ResourceRecords = append(recordSet.ResourceRecords, r53Types.ResourceRecord{Value: aws.String(value)})
+		}
+		batch = append(batch, r53Types.Change{Action: r53Types.ChangeActionUpsert, ResourceRecordSet: recordSet})
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	_, err = p.client.ChangeResourceRecordSets(context.Background(), &r53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.zoneID),
+		ChangeBatch: &r53Types.ChangeBatch{
+			Comment: aws.String("dockdns"),
+			Changes: batch,
+		},
+	})
+	return err
+}
+
+// recordSet returns the record set with the given name and type, or nil if it does not exist
+func (p route53Provider) recordSet(domain, recordType string) (*r53Types.ResourceRecordSet, error) {
+	sets, err := p.client.ListResourceRecordSets(context.Background(), &r53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(p.zoneID),
+		StartRecordName: aws.String(domain),
+		StartRecordType: r53Types.RRType(recordType),
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listing starts at the given name and type, the first set might belong to the next name
+	for _, set := range sets.ResourceRecordSets {
+		if strings.EqualFold(unescapeName(aws.ToString(set.Name)), domain) && string(set.Type) == recordType {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+func mapRecords(set r53Types.ResourceRecordSet) []dns.Record {
+	var records []dns.Record
+
+	name := unescapeName(aws.ToString(set.Name))
+	for _, r := range set.ResourceRecords {
+		content := aws.ToString(r.Value)
+		var priority int
+		switch set.Type {
+		case r53Types.RRTypeCname:
+			content = strings.TrimSuffix(content, ".")
+		case r53Types.RRTypeTxt:
+			content = dns.UnquoteTXT(content)
+		case r53Types.RRTypeMx:
+			priority, content = dns.ParseMX(content)
+		}
+		records = append(records, dns.Record{
+			ID:       dns.RecordID(name, string(set.Type)),
+			Name:     name,
+			Type:     string(set.Type),
+			Content:  content,
+			TTL:      int(aws.ToInt64(set.TTL)),
+			Priority: priority,
+		})
+	}
+
+	return records
+}
+
+// unescapeName removes the trailing dot and decodes octal escapes, e.g. '\052' for a wildcard
+func unescapeName(name string) string {
+	name = strings.TrimSuffix(name, ".")
+
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
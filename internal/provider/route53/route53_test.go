@@ -0,0 +1,173 @@
+package route53
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const zoneID = "Z123"
+
+type resourceRecordSet struct {
+	Name            string   `xml:"Name"`
+	Type            string   `xml:"Type"`
+	TTL             int      `xml:"TTL"`
+	ResourceRecords []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+type changeRequest struct {
+	Changes []struct {
+		Action            string            `xml:"Action"`
+		ResourceRecordSet resourceRecordSet `xml:"ResourceRecordSet"`
+	} `xml:"ChangeBatch>Changes>Change"`
+}
+
+// fakeRoute53 serves the record sets of a single hosted zone. Like Route 53, it rejects deletions of
+// record sets that don't match the existing set exactly.
+type fakeRoute53 struct {
+	sets    []resourceRecordSet
+	batches int
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/hostedzone/"+zoneID+"/rrset") && !strings.HasSuffix(r.URL.Path, "/hostedzone/"+zoneID+"/rrset/") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+
+	if r.Method == http.MethodGet {
+		sets := f.sets
+		if name := r.URL.Query().Get("name"); name != "" {
+			sets = slices.DeleteFunc(slices.Clone(sets), func(set resourceRecordSet) bool {
+				return set.Name != strings.TrimSuffix(name, ".")+"." || set.Type != r.URL.Query().Get("type")
+			})
+		}
+		fmt.Fprint(w, `<ListResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><ResourceRecordSets>`)
+		for _, set := range sets {
+			fmt.Fprintf(w, `<ResourceRecordSet><Name>%s</Name><Type>%s</Type><TTL>%d</TTL><ResourceRecords>`, set.Name, set.Type, set.TTL)
+			for _, value := range set.ResourceRecords {
+				fmt.Fprintf(w, `<ResourceRecord><Value>%s</Value></ResourceRecord>`, escape(value))
+			}
+			fmt.Fprint(w, `</ResourceRecords></ResourceRecordSet>`)
+		}
+		fmt.Fprint(w, `</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListResourceRecordSetsResponse>`)
+		return
+	}
+
+	var req changeRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sets := slices.Clone(f.sets)
+	for _, change := range req.Changes {
+		set := change.ResourceRecordSet
+		set.Name = strings.TrimSuffix(set.Name, ".") + "."
+		idx := slices.IndexFunc(sets, func(s resourceRecordSet) bool { return s.Name == set.Name && s.Type == set.Type })
+
+		switch change.Action {
+		case "UPSERT":
+			if idx >= 0 {
+				sets[idx] = set
+			} else {
+				sets = append(sets, set)
+			}
+		case "DELETE":
+			if idx < 0 || !reflect.DeepEqual(sets[idx], set) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidChangeBatch</Code><Message>record set does not match</Message></Error></ErrorResponse>`)
+				return
+			}
+			sets = slices.Delete(sets, idx, idx+1)
+		}
+	}
+	f.sets = sets
+	f.batches++
+	fmt.Fprint(w, `<ChangeResourceRecordSetsResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status><SubmittedAt>2026-01-01T00:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+}
+
+func escape(value string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(value))
+	return sb.String()
+}
+
+func newTestProvider(t *testing.T, fake *fakeRoute53) route53Provider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	p, err := New(server.URL, zoneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestApply(t *testing.T) {
+	fake := &fakeRoute53{sets: []resourceRecordSet{
+		{Name: "example.com.", Type: "TXT", TTL: 300, ResourceRecords: []string{`"v=spf1 -all"`, `"token=old"`}},
+		{Name: "old.example.com.", Type: "A", TTL: 300, ResourceRecords: []string{"10.0.0.1"}},
+	}}
+	p := newTestProvider(t, fake)
+
+	err := p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 300}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=old", TTL: 300}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "old.example.com", Type: constants.RecordTypeA, Content: "10.0.0.1"}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10, TTL: 600}},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := []resourceRecordSet{
+		{Name: "example.com.", Type: "TXT", TTL: 300, ResourceRecords: []string{`"v=spf1 -all"`, `"token=new"`}},
+		{Name: "example.com.", Type: "MX", TTL: 600, ResourceRecords: []string{"10 mx.example.com."}},
+	}
+	if !reflect.DeepEqual(fake.sets, want) {
+		t.Errorf("record sets = %+v, want %+v", fake.sets, want)
+	}
+	if fake.batches != 1 {
+		t.Errorf("sent %d change batches, want 1", fake.batches)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := []dns.Record{
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300},
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 300},
+		{ID: "example.com/MX", Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 600, Priority: 10},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("List() = %+v, want %+v", records, wantRecords)
+	}
+}
+
+func TestApplyWithoutChanges(t *testing.T) {
+	fake := &fakeRoute53{sets: []resourceRecordSet{{Name: "example.com.", Type: "A", TTL: 300, ResourceRecords: []string{"10.0.0.1"}}}}
+	p := newTestProvider(t, fake)
+
+	err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300}}})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if fake.batches != 0 {
+		t.Errorf("sent %d change batches, want none", fake.batches)
+	}
+}
@@ -0,0 +1,292 @@
+package routeros
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+const (
+	staticPath = "/rest/ip/dns/static"
+
+	// Entries created by dockdns carry this comment, optionally followed by ': ' and the record comment
+	commentTag = "dockdns"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	Comment:     true,
+	TTL:         true,
+}
+
+type routerosProvider struct {
+	client jsonapi.Client
+	zone   string
+}
+
+type entry struct {
+	ID       string `json:".id,omitempty"`
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Address  string `json:"address,omitempty"`
+	CName    string `json:"cname,omitempty"`
+	Text     string `json:"text,omitempty"`
+	TTL      string `json:"ttl,omitempty"`
+	Comment  string `json:"comment"`
+	Disabled string `json:"disabled,omitempty"`
+
+	MXExchange   string `json:"mx-exchange,omitempty"`
+	MXPreference string `json:"mx-preference,omitempty"`
+}
+
+func New(apiURL, username, password, zone string) (routerosProvider, error) {
+	if apiURL == "" {
+		return routerosProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+
+	return routerosProvider{
+		client: jsonapi.New("routeros", apiURL, func(req *http.Request) {
+			req.SetBasicAuth(username, password)
+		}),
+		zone: zone,
+	}, nil
+}
+
+func (p routerosProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+// List returns the entries below the zone that are tagged as managed by dockdns. Hand-made entries are never returned
+func (p routerosProvider) List() ([]dns.Record, error) {
+	var entries []entry
+	if err := p.client.Do(http.MethodGet, staticPath, nil, &entries); err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, e := range entries {
+		if record, ok := mapRecord(e); ok && p.inZone(record.Name) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p routerosProvider) Get(domain, recordType string) (dns.Record, error) {
+	var entries []entry
+	if err := p.client.Do(http.MethodGet, staticPath+"?name="+url.QueryEscape(domain), nil, &entries); err != nil {
+		return dns.Record{}, err
+	}
+
+	for _, e := range entries {
+		if record, ok := mapRecord(e); ok && record.Type == recordType && strings.EqualFold(record.Name, domain) {
+			return record, nil
+		}
+	}
+	return dns.Record{}, nil
+}
+
+func (p routerosProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+	if err := p.checkConflicts(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	var created entry
+	if err := p.client.Do(http.MethodPut, staticPath, toEntry(record), &created); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = created.ID
+	return record, nil
+}
+
+func (p routerosProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.validate(record); err != nil {
+		return dns.Record{}, err
+	}
+
+	if record.ID == "" {
+		existing, err := p.Get(record.Name, record.Type)
+		if err != nil {
+			return dns.Record{}, err
+		}
+		if existing.ID == "" {
+			return p.Create(record)
+		}
+		record.ID = existing.ID
+	}
+
+	if err := p.client.Do(http.MethodPatch, staticPath+"/"+url.PathEscape(record.ID), toEntry(record), nil); err != nil {
+		return dns.Record{}, err
+	}
+	return record, nil
+}
+
+func (p routerosProvider) Delete(record dns.Record) error {
+	if record.ID == "" {
+		existing, err := p.Get(record.Name, record.Type)
+		if err != nil || existing.ID == "" {
+			return err
+		}
+		record.ID = existing.ID
+	}
+
+	// Make sure the entry is still managed by dockdns, IDs are reused by RouterOS
+	var e entry
+	if err := p.client.Do(http.MethodGet, staticPath+"/"+url.PathEscape(record.ID), nil, &e); err != nil {
+		return err
+	}
+	if _, ok := mapRecord(e); !ok {
+		return fmt.Errorf("refusing to delete %s, the entry is not managed by dockdns", record.Name)
+	}
+
+	return p.client.Do(http.MethodDelete, staticPath+"/"+url.PathEscape(record.ID), nil, nil)
+}
+
+// checkConflicts refuses to create a record next to a hand-made entry it would conflict with, e.g. a second
+// address for a name that is already resolved by the router. TXT and MX values can coexist with hand-made
+// entries of the same name and only conflict if the value already exists.
+func (p routerosProvider) checkConflicts(record dns.Record) error {
+	var entries []entry
+	if err := p.client.Do(http.MethodGet, staticPath+"?name="+url.QueryEscape(record.Name), nil, &entries); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if _, managed := mapRecord(e); managed || e.Disabled == "true" || !strings.EqualFold(e.Name, record.Name) {
+			continue
+		}
+
+		existing := toRecord(e)
+		conflict := existing.Type == record.Type
+		if record.Type == constants.RecordTypeTXT || record.Type == constants.RecordTypeMX {
+			conflict = conflict && existing.Content == record.Content
+		}
+		if conflict || existing.Type == constants.RecordTypeCNAME || record.Type == constants.RecordTypeCNAME {
+			return fmt.Errorf("refusing to create %s record %s, it conflicts with the %s entry %s that is not managed by dockdns", record.Type, record.Name, existing.Type, e.ID)
+		}
+	}
+	return nil
+}
+
+func (p routerosProvider) validate(record dns.Record) error {
+	if err := capabilities.Validate(record); err != nil {
+		return fmt.Errorf("invalid record %s: %w", record.Name, err)
+	}
+	if !p.inZone(record.Name) {
+		return fmt.Errorf("%s is not part of zone %s", record.Name, p.zone)
+	}
+	return nil
+}
+
+func (p routerosProvider) inZone(name string) bool {
+	return strings.EqualFold(name, p.zone) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(p.zone))
+}
+
+func toEntry(record dns.Record) entry {
+	e := entry{
+		Name:    record.Name,
+		Type:    record.Type,
+		Comment: commentTag,
+	}
+	if record.Comment != "" {
+		e.Comment += ": " + record.Comment
+	}
+	if record.TTL > 0 {
+		e.TTL = strconv.Itoa(record.TTL) + "s"
+	}
+
+	switch record.Type {
+	case constants.RecordTypeCNAME:
+		e.CName = record.Content
+	case constants.RecordTypeTXT:
+		e.Text = record.Content
+	case constants.RecordTypeMX:
+		e.MXExchange = record.Content
+		e.MXPreference = strconv.Itoa(record.Priority)
+	default:
+		e.Address = record.Content
+	}
+	return e
+}
+
+// mapRecord returns false for entries not managed by dockdns
+func mapRecord(e entry) (dns.Record, bool) {
+	comment, managed := strings.CutPrefix(e.Comment, commentTag)
+	if !managed || (comment != "" && !strings.HasPrefix(comment, ": ")) || e.Disabled == "true" {
+		return dns.Record{}, false
+	}
+
+	record := toRecord(e)
+	if !capabilities.Supports(record.Type) {
+		return dns.Record{}, false
+	}
+	record.Comment = strings.TrimPrefix(comment, ": ")
+	return record, true
+}
+
+// toRecord converts an entry regardless of whether it is managed by dockdns
+func toRecord(e entry) dns.Record {
+	// Entries without type are A records, RouterOS omits the default value
+	recordType := e.Type
+	if recordType == "" {
+		recordType = constants.RecordTypeA
+	}
+
+	content := e.Address
+	var priority int
+	switch recordType {
+	case constants.RecordTypeCNAME:
+		content = e.CName
+	case constants.RecordTypeTXT:
+		content = e.Text
+	case constants.RecordTypeMX:
+		content = e.MXExchange
+		priority, _ = strconv.Atoi(e.MXPreference)
+	}
+
+	return dns.Record{
+		ID:       e.ID,
+		Name:     e.Name,
+		Type:     recordType,
+		Content:  content,
+		TTL:      parseTTL(e.TTL),
+		Priority: priority,
+	}
+}
+
+// parseTTL parses RouterOS durations like '1d', '5m30s' or '1d00:05:00' into seconds
+func parseTTL(ttl string) int {
+	var total time.Duration
+	for _, unit := range []struct {
+		suffix string
+		length time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if value, rest, ok := strings.Cut(ttl, unit.suffix); ok {
+			n, _ := strconv.Atoi(value)
+			total += time.Duration(n) * unit.length
+			ttl = rest
+		}
+	}
+
+	if h, rest, ok := strings.Cut(ttl, ":"); ok {
+		m, s, _ := strings.Cut(rest, ":")
+		hours, _ := strconv.Atoi(h)
+		minutes, _ := strconv.Atoi(m)
+		seconds, _ := strconv.Atoi(s)
+		total += time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	} else if ttl != "" {
+		d, _ := time.ParseDuration(ttl)
+		total += d
+	}
+	return int(total.Seconds())
+}
@@ -0,0 +1,158 @@
+package routeros
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeRouterOS serves the static DNS entries of the REST API
+type fakeRouterOS struct {
+	entries []entry
+	nextID  int
+}
+
+func (f *fakeRouterOS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, staticPath), "/")
+	idx := slices.IndexFunc(f.entries, func(e entry) bool { return e.ID == id })
+	if id != "" && idx < 0 {
+		http.Error(w, `{"error":404,"message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		entries := []entry{}
+		for _, e := range f.entries {
+			if name := r.URL.Query().Get("name"); name == "" || e.Name == name {
+				entries = append(entries, e)
+			}
+		}
+		json.NewEncoder(w).Encode(entries)
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.entries[idx])
+	case r.Method == http.MethodPut:
+		var e entry
+		json.NewDecoder(r.Body).Decode(&e)
+		f.nextID++
+		e.ID = fmt.Sprintf("*%X", f.nextID)
+		f.entries = append(f.entries, e)
+		json.NewEncoder(w).Encode(e)
+	case r.Method == http.MethodPatch:
+		var e entry
+		json.NewDecoder(r.Body).Decode(&e)
+		e.ID = id
+		f.entries[idx] = e
+	case r.Method == http.MethodDelete:
+		f.entries = slices.Delete(f.entries, idx, idx+1)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeRouterOS) routerosProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "admin", "secret", "office.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProvider(t *testing.T) {
+	fake := &fakeRouterOS{entries: []entry{
+		{ID: "*100", Name: "router.office.lan", Address: "192.168.88.1", Comment: ""},
+		{ID: "*101", Name: "office.lan", Type: "TXT", Text: "v=spf1 -all", Comment: "mail"},
+		{ID: "*102", Name: "other.lan", Address: "10.0.0.1", Comment: commentTag},
+	}}
+	p := newTestProvider(t, fake)
+
+	created, err := p.Create(dns.Record{Name: "nas.office.lan", Type: constants.RecordTypeA, Content: "192.168.88.10", TTL: 300, Comment: "nas"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := p.Create(dns.Record{Name: "office.lan", Type: constants.RecordTypeTXT, Content: "token=1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := p.Update(dns.Record{Name: "nas.office.lan", Type: constants.RecordTypeA, Content: "192.168.88.11", TTL: 60}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dns.Record{
+		{ID: created.ID, Name: "nas.office.lan", Type: constants.RecordTypeA, Content: "192.168.88.11", TTL: 60},
+		{ID: "*2", Name: "office.lan", Type: constants.RecordTypeTXT, Content: "token=1"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	if err := p.Delete(dns.Record{Name: "router.office.lan", Type: constants.RecordTypeA, ID: "*100"}); err == nil {
+		t.Error("Delete() removed a hand-made entry")
+	}
+	if err := p.Delete(want[0]); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(fake.entries) != 4 {
+		t.Errorf("entries = %+v, want the hand-made entries and the TXT record", fake.entries)
+	}
+}
+
+func TestCreateRefusesConflicts(t *testing.T) {
+	fake := &fakeRouterOS{entries: []entry{
+		{ID: "*100", Name: "router.office.lan", Address: "192.168.88.1"},
+		{ID: "*101", Name: "office.lan", Type: "TXT", Text: "v=spf1 -all"},
+		{ID: "*102", Name: "alias.office.lan", Type: "CNAME", CName: "router.office.lan"},
+		{ID: "*103", Name: "old.office.lan", Address: "192.168.88.2", Disabled: "true"},
+	}}
+	p := newTestProvider(t, fake)
+
+	tests := []struct {
+		record   dns.Record
+		conflict bool
+	}{
+		{dns.Record{Name: "router.office.lan", Type: constants.RecordTypeA, Content: "192.168.88.2"}, true},
+		{dns.Record{Name: "router.office.lan", Type: constants.RecordTypeCNAME, Content: "nas.office.lan"}, true},
+		{dns.Record{Name: "alias.office.lan", Type: constants.RecordTypeTXT, Content: "token=1"}, true},
+		{dns.Record{Name: "office.lan", Type: constants.RecordTypeTXT, Content: "v=spf1 -all"}, true},
+		{dns.Record{Name: "office.lan", Type: constants.RecordTypeTXT, Content: "token=1"}, false},
+		{dns.Record{Name: "router.office.lan", Type: constants.RecordTypeAAAA, Content: "fd00::1"}, false},
+		{dns.Record{Name: "old.office.lan", Type: constants.RecordTypeA, Content: "192.168.88.3"}, false},
+	}
+	for _, tt := range tests {
+		_, err := p.Create(tt.record)
+		if conflict := err != nil; conflict != tt.conflict {
+			t.Errorf("Create(%s %s) error = %v, want conflict %v", tt.record.Type, tt.record.Name, err, tt.conflict)
+		}
+	}
+	if len(fake.entries) != 7 {
+		t.Errorf("created %d entries, want 3", len(fake.entries)-4)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := map[string]int{
+		"":           0,
+		"5m30s":      330,
+		"1d":         86400,
+		"1w2d":       9 * 86400,
+		"1d00:05:00": 86400 + 300,
+		"01:00:00":   3600,
+	}
+	for ttl, want := range tests {
+		if got := parseTTL(ttl); got != want {
+			t.Errorf("parseTTL(%q) = %d, want %d", ttl, got, want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package technitium
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/jsonapi"
+)
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	Comment:     true,
+	TTL:         true,
+}
+
+type technitiumProvider struct {
+	apiURL   string
+	apiToken string
+	zone     string
+	client   *http.Client
+}
+
+type apiResponse struct {
+	Status       string          `json:"status"`
+	ErrorMessage string          `json:"errorMessage"`
+	Response     json.RawMessage `json:"response"`
+}
+
+type record struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	TTL      int    `json:"ttl"`
+	Disabled bool   `json:"disabled"`
+	Comments string `json:"comments"`
+	RData    struct {
+		IPAddress  string `json:"ipAddress"`
+		CName      string `json:"cname"`
+		Text       string `json:"text"`
+		Exchange   string `json:"exchange"`
+		Preference int    `json:"preference"`
+	} `json:"rData"`
+}
+
+func New(apiURL, apiToken, zone string) (technitiumProvider, error) {
+	if apiURL == "" {
+		return technitiumProvider{}, fmt.Errorf("no api url set for zone %s", zone)
+	}
+
+	return technitiumProvider{
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		apiToken: apiToken,
+		zone:     zone,
+		client:   jsonapi.NewHTTPClient(),
+	}, nil
+}
+
+func (p technitiumProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p technitiumProvider) List() ([]dns.Record, error) {
+	var result struct {
+		Records []record `json:"records"`
+	}
+	params := url.Values{"zone": {p.zone}, "domain": {p.zone}, "listZone": {"true"}}
+	if err := p.do("/api/zones/records/get", params, &result); err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, r := range result.Records {
+		if r.Disabled || !capabilities.Supports(r.Type) {
+			continue
+		}
+		records = append(records, mapRecord(r))
+	}
+	return records, nil
+}
+
+func (p technitiumProvider) Get(domain, recordType string) (dns.Record, error) {
+	var result struct {
+		Records []record `json:"records"`
+	}
+	if err := p.do("/api/zones/records/get", url.Values{"zone": {p.zone}, "domain": {domain}}, &result); err != nil {
+		return dns.Record{}, err
+	}
+
+	for _, r := range result.Records {
+		if !r.Disabled && r.Type == recordType && strings.EqualFold(r.Name, domain) {
+			return mapRecord(r), nil
+		}
+	}
+	return dns.Record{}, nil
+}
+
+func (p technitiumProvider) Create(r dns.Record) (dns.Record, error) {
+	params := p.params(r)
+	setValue(params, r, "")
+
+	if err := p.do("/api/zones/records/add", params, nil); err != nil {
+		return dns.Record{}, err
+	}
+	r.ID = dns.RecordID(r.Name, r.Type)
+	return r, nil
+}
+
+func (p technitiumProvider) Update(r dns.Record) (dns.Record, error) {
+	// Address records are identified by their current value, which has to be sent along with the new one
+	existing, err := p.Get(r.Name, r.Type)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	if existing.ID == "" {
+		return p.Create(r)
+	}
+
+	params := p.params(r)
+	setValue(params, existing, r.Content)
+
+	if err := p.do("/api/zones/records/update", params, nil); err != nil {
+		return dns.Record{}, err
+	}
+	r.ID = dns.RecordID(r.Name, r.Type)
+	return r, nil
+}
+
+func (p technitiumProvider) Delete(r dns.Record) error {
+	params := url.Values{
+		"zone":   {p.zone},
+		"domain": {r.Name},
+		"type":   {r.Type},
+	}
+	setValue(params, r, "")
+
+	return p.do("/api/zones/records/delete", params, nil)
+}
+
+func (p technitiumProvider) params(r dns.Record) url.Values {
+	params := url.Values{
+		"zone":     {p.zone},
+		"domain":   {r.Name},
+		"type":     {r.Type},
+		"comments": {r.Comment},
+	}
+	if r.TTL > 0 {
+		params.Set("ttl", strconv.Itoa(r.TTL))
+	}
+	return params
+}
+
+// setValue sets the record data parameters. For updates, newValue holds the new value of the record
+func setValue(params url.Values, r dns.Record, newValue string) {
+	value := r.Content
+	switch r.Type {
+	case constants.RecordTypeA, constants.RecordTypeAAAA:
+		params.Set("ipAddress", value)
+		if newValue != "" {
+			params.Set("newIpAddress", newValue)
+		}
+	case constants.RecordTypeCNAME:
+		if newValue != "" {
+			value = newValue
+		}
+		params.Set("cname", value)
+	case constants.RecordTypeTXT:
+		params.Set("text", value)
+		if newValue != "" {
+			params.Set("newText", newValue)
+		}
+	case constants.RecordTypeMX:
+		// MX records are only created and deleted, never updated
+		params.Set("exchange", value)
+		params.Set("preference", strconv.Itoa(r.Priority))
+	}
+}
+
+func (p technitiumProvider) do(path string, params url.Values, result any) error {
+	params.Set("token", p.apiToken)
+
+	// Send the parameters as form body, so the token does not end up in access logs
+	resp, err := p.client.PostForm(p.apiURL+path, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("technitium api returned %s for zone %s", resp.Status, p.zone)
+	}
+
+	var apiResp apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("could not decode technitium response: %w", err)
+	}
+	if apiResp.Status != "ok" {
+		return fmt.Errorf("technitium api call %s failed (%s): %s", path, apiResp.Status, apiResp.ErrorMessage)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(apiResp.Response, result)
+}
+
+func mapRecord(r record) dns.Record {
+	content := r.RData.IPAddress
+	switch r.Type {
+	case constants.RecordTypeCNAME:
+		content = r.RData.CName
+	case constants.RecordTypeTXT:
+		content = r.RData.Text
+	case constants.RecordTypeMX:
+		content = r.RData.Exchange
+	}
+
+	return dns.Record{
+		ID:       dns.RecordID(r.Name, r.Type),
+		Name:     r.Name,
+		Type:     r.Type,
+		Content:  content,
+		TTL:      r.TTL,
+		Comment:  r.Comments,
+		Priority: r.RData.Preference,
+	}
+}
@@ -0,0 +1,157 @@
+package technitium
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+// fakeTechnitium serves the records of the zone and records the form parameters of every change
+type fakeTechnitium struct {
+	records []map[string]any
+	calls   []call
+}
+
+type call struct {
+	path string
+	form url.Values
+}
+
+func (f *fakeTechnitium) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.RawQuery != "" {
+		http.Error(w, "parameters have to be sent as form body", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("token") != "secret" {
+		json.NewEncoder(w).Encode(map[string]any{"status": "invalid-token", "errorMessage": "Invalid token or session expired."})
+		return
+	}
+
+	form := r.PostForm
+	form.Del("token")
+	switch r.URL.Path {
+	case "/api/zones/records/get":
+		var records []map[string]any
+		for _, record := range f.records {
+			if form.Get("listZone") == "true" || strings.EqualFold(record["name"].(string), form.Get("domain")) {
+				records = append(records, record)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "ok", "response": map[string]any{"records": records}})
+	case "/api/zones/records/add", "/api/zones/records/update", "/api/zones/records/delete":
+		f.calls = append(f.calls, call{path: r.URL.Path, form: form})
+		json.NewEncoder(w).Encode(map[string]any{"status": "ok", "response": map[string]any{}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeTechnitium) technitiumProvider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "secret", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestList(t *testing.T) {
+	fake := &fakeTechnitium{records: []map[string]any{
+		{"name": "home.lan", "type": "SOA", "ttl": 900, "rData": map[string]any{"primaryNameServer": "ns.home.lan"}},
+		{"name": "nas.home.lan", "type": "A", "ttl": 300, "comments": "managed by dockdns", "rData": map[string]any{"ipAddress": "10.0.0.1"}},
+		{"name": "old.home.lan", "type": "A", "ttl": 300, "disabled": true, "rData": map[string]any{"ipAddress": "10.0.0.9"}},
+		{"name": "media.home.lan", "type": "CNAME", "ttl": 60, "rData": map[string]any{"cname": "nas.home.lan"}},
+		{"name": "home.lan", "type": "TXT", "ttl": 300, "rData": map[string]any{"text": "v=spf1 -all"}},
+		{"name": "home.lan", "type": "MX", "ttl": 300, "rData": map[string]any{"exchange": "mx.home.lan", "preference": 10}},
+	}}
+	p := newTestProvider(t, fake)
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	// Disabled records and unsupported types are skipped, comments are mapped
+	want := []dns.Record{
+		{ID: "nas.home.lan/A", Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.1", TTL: 300, Comment: "managed by dockdns"},
+		{ID: "media.home.lan/CNAME", Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "nas.home.lan", TTL: 60},
+		{ID: "home.lan/TXT", Name: "home.lan", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 300},
+		{ID: "home.lan/MX", Name: "home.lan", Type: constants.RecordTypeMX, Content: "mx.home.lan", TTL: 300, Priority: 10},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	record, err := p.Get("old.home.lan", constants.RecordTypeA)
+	if err != nil || record.ID != "" {
+		t.Errorf("Get() = %+v, %v, want no record for a disabled record", record, err)
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	fake := &fakeTechnitium{records: []map[string]any{
+		{"name": "nas.home.lan", "type": "A", "ttl": 300, "rData": map[string]any{"ipAddress": "10.0.0.1"}},
+		{"name": "home.lan", "type": "TXT", "ttl": 300, "rData": map[string]any{"text": "token=old"}},
+		{"name": "media.home.lan", "type": "CNAME", "ttl": 300, "rData": map[string]any{"cname": "nas.home.lan"}},
+	}}
+	p := newTestProvider(t, fake)
+
+	if _, err := p.Create(dns.Record{Name: "home.lan", Type: constants.RecordTypeMX, Content: "mx.home.lan", TTL: 300, Priority: 10, Comment: "dockdns"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// Updates send the current value along with the new one
+	if _, err := p.Update(dns.Record{Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2", TTL: 60, Comment: "dockdns"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := p.Update(dns.Record{Name: "home.lan", Type: constants.RecordTypeTXT, Content: "token=new", TTL: 300}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := p.Update(dns.Record{Name: "media.home.lan", Type: constants.RecordTypeCNAME, Content: "app.home.lan", TTL: 300}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// Updating a missing record creates it
+	if _, err := p.Update(dns.Record{Name: "app.home.lan", Type: constants.RecordTypeAAAA, Content: "fd00::1"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := p.Delete(dns.Record{Name: "nas.home.lan", Type: constants.RecordTypeA, Content: "10.0.0.2"}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	want := []call{
+		{"/api/zones/records/add", url.Values{"zone": {"home.lan"}, "domain": {"home.lan"}, "type": {"MX"}, "comments": {"dockdns"}, "ttl": {"300"}, "exchange": {"mx.home.lan"}, "preference": {"10"}}},
+		{"/api/zones/records/update", url.Values{"zone": {"home.lan"}, "domain": {"nas.home.lan"}, "type": {"A"}, "comments": {"dockdns"}, "ttl": {"60"}, "ipAddress": {"10.0.0.1"}, "newIpAddress": {"10.0.0.2"}}},
+		{"/api/zones/records/update", url.Values{"zone": {"home.lan"}, "domain": {"home.lan"}, "type": {"TXT"}, "comments": {""}, "ttl": {"300"}, "text": {"token=old"}, "newText": {"token=new"}}},
+		{"/api/zones/records/update", url.Values{"zone": {"home.lan"}, "domain": {"media.home.lan"}, "type": {"CNAME"}, "comments": {""}, "ttl": {"300"}, "cname": {"app.home.lan"}}},
+		{"/api/zones/records/add", url.Values{"zone": {"home.lan"}, "domain": {"app.home.lan"}, "type": {"AAAA"}, "comments": {""}, "ipAddress": {"fd00::1"}}},
+		{"/api/zones/records/delete", url.Values{"zone": {"home.lan"}, "domain": {"nas.home.lan"}, "type": {"A"}, "ipAddress": {"10.0.0.2"}}},
+	}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	fake := &fakeTechnitium{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	p, err := New(server.URL, "wrong", "home.lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.List(); err == nil || !strings.Contains(err.Error(), "Invalid token or session expired.") {
+		t.Errorf("List() error = %v, want the error message of the API", err)
+	}
+}
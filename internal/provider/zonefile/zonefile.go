@@ -0,0 +1,231 @@
+package zonefile
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+	"github.com/Tarow/dockdns/internal/provider/rfc1035"
+	mdns "github.com/miekg/dns"
+)
+
+const reloadTimeout = 30 * time.Second
+
+var capabilities = dns.Capabilities{
+	RecordTypes: []string{constants.RecordTypeA, constants.RecordTypeAAAA, constants.RecordTypeCNAME, constants.RecordTypeTXT, constants.RecordTypeMX},
+	TTL:         true,
+}
+
+type zonefileProvider struct {
+	path          string
+	zone          string
+	reloadCommand []string
+	mu            *sync.Mutex
+}
+
+func New(path, zone, reloadCommand string) (zonefileProvider, error) {
+	if path == "" {
+		return zonefileProvider{}, fmt.Errorf("no zone file path set for zone %s", zone)
+	}
+
+	return zonefileProvider{
+		path:          path,
+		zone:          mdns.Fqdn(zone),
+		reloadCommand: strings.Fields(reloadCommand),
+		mu:            &sync.Mutex{},
+	}, nil
+}
+
+func (p zonefileProvider) Capabilities() dns.Capabilities {
+	return capabilities
+}
+
+func (p zonefileProvider) List() ([]dns.Record, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rrs, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.Record
+	for _, rr := range rrs {
+		if record, ok := rfc1035.MapRecord(rr); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (p zonefileProvider) Get(domain, recordType string) (dns.Record, error) {
+	records, err := p.List()
+	if err != nil {
+		return dns.Record{}, err
+	}
+
+	idx := slices.IndexFunc(records, func(r dns.Record) bool {
+		return strings.EqualFold(r.Name, domain) && r.Type == recordType
+	})
+	if idx < 0 {
+		return dns.Record{}, nil
+	}
+	return records[idx], nil
+}
+
+func (p zonefileProvider) Create(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionCreate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p zonefileProvider) Update(record dns.Record) (dns.Record, error) {
+	if err := p.Apply([]dns.Change{{Action: dns.ActionUpdate, Record: record}}); err != nil {
+		return dns.Record{}, err
+	}
+	record.ID = dns.RecordID(record.Name, record.Type)
+	return record, nil
+}
+
+func (p zonefileProvider) Delete(record dns.Record) error {
+	return p.Apply([]dns.Change{{Action: dns.ActionDelete, Record: record}})
+}
+
+// Apply changes the records in memory and rewrites the zone file once, with an incremented SOA serial.
+// Afterwards the reload command is run, if configured.
+func (p zonefileProvider) Apply(changes []dns.Change) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rrs, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		rr, err := rfc1035.NewRR(change.Record)
+		if err != nil {
+			return err
+		}
+		sameSet := func(existing mdns.RR) bool {
+			return strings.EqualFold(existing.Header().Name, rr.Header().Name) && existing.Header().Rrtype == rr.Header().Rrtype
+		}
+		sameRecord := func(existing mdns.RR) bool {
+			return sameSet(existing) && mdns.IsDuplicate(existing, rr)
+		}
+
+		switch change.Action {
+		case dns.ActionCreate:
+			if !slices.ContainsFunc(rrs, sameRecord) {
+				rrs = append(rrs, rr)
+			}
+		case dns.ActionUpdate:
+			idx := slices.IndexFunc(rrs, sameSet)
+			rrs = slices.DeleteFunc(rrs, sameSet)
+			if idx < 0 {
+				idx = len(rrs)
+			}
+			rrs = slices.Insert(rrs, idx, rr)
+		case dns.ActionDelete:
+			rrs = slices.DeleteFunc(rrs, sameRecord)
+		}
+	}
+
+	if err := p.write(rrs); err != nil {
+		return err
+	}
+	return p.reload()
+}
+
+func (p zonefileProvider) read() ([]mdns.RR, error) {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open zone file: %w", err)
+	}
+	defer file.Close()
+
+	var rrs []mdns.RR
+	parser := mdns.NewZoneParser(file, p.zone, p.path)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("could not parse zone file: %w", err)
+	}
+	return rrs, nil
+}
+
+// write replaces the zone file atomically by writing a temporary file next to it and renaming it.
+// The file is generated from the records, comments and formatting of the original file are dropped.
+func (p zonefileProvider) write(rrs []mdns.RR) error {
+	soaIdx := slices.IndexFunc(rrs, func(rr mdns.RR) bool { return rr.Header().Rrtype == mdns.TypeSOA })
+	if soaIdx < 0 {
+		return fmt.Errorf("zone file %s has no SOA record", p.path)
+	}
+	soa := rrs[soaIdx].(*mdns.SOA)
+	soa.Serial = nextSerial(soa.Serial, time.Now())
+
+	// The SOA record has to be the first record of the zone
+	rrs = slices.Insert(slices.Delete(rrs, soaIdx, soaIdx+1), 0, mdns.RR(soa))
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), "."+filepath.Base(p.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary zone file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintf(w, "; Managed by dockdns, manual changes to managed records will be overwritten\n$ORIGIN %s\n", p.zone)
+	for _, rr := range rrs {
+		fmt.Fprintln(w, rr.String())
+	}
+
+	err = errors.Join(w.Flush(), tmp.Chmod(info.Mode()), tmp.Sync(), tmp.Close())
+	if err != nil {
+		return fmt.Errorf("could not write zone file: %w", err)
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
+
+func (p zonefileProvider) reload() error {
+	if len(p.reloadCommand) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, p.reloadCommand[0], p.reloadCommand[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("zone file was written, but the reload command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	slog.Debug("reloaded zone", "zone", p.zone, "output", strings.TrimSpace(string(output)))
+	return nil
+}
+
+// nextSerial increments the serial. Serials in the YYYYMMDDnn format are moved to the current date
+func nextSerial(serial uint32, now time.Time) uint32 {
+	dateSerial := uint32(now.Year()*1000000 + int(now.Month())*10000 + now.Day()*100)
+	if serial >= 1970010100 && serial < dateSerial {
+		return dateSerial
+	}
+	return serial + 1
+}
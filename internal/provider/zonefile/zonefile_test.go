@@ -0,0 +1,95 @@
+package zonefile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tarow/dockdns/internal/constants"
+	"github.com/Tarow/dockdns/internal/dns"
+)
+
+const testZone = `$ORIGIN example.com.
+$TTL 3600
+; hand-written comment
+@       IN SOA ns.example.com. admin.example.com. 2026010100 3600 600 86400 300
+@       IN NS  ns.example.com.
+ns      IN A   10.0.0.53
+old     IN A   10.0.0.9
+@       IN TXT "v=spf1 -all"
+`
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "example.com.zone")
+	if err := os.WriteFile(path, []byte(testZone), 0640); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := filepath.Join(dir, "reloaded")
+
+	p, err := New(path, "example.com", "touch "+reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Apply([]dns.Change{
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 300}},
+		{Action: dns.ActionCreate, Record: dns.Record{Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", Priority: 10, TTL: 300}},
+		{Action: dns.ActionDelete, Record: dns.Record{Name: "old.example.com", Type: constants.RecordTypeA, Content: "10.0.0.9", TTL: 3600}},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	records, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dns.Record{
+		{ID: "ns.example.com/A", Name: "ns.example.com", Type: constants.RecordTypeA, Content: "10.0.0.53", TTL: 3600},
+		{ID: "example.com/TXT", Name: "example.com", Type: constants.RecordTypeTXT, Content: "v=spf1 -all", TTL: 3600},
+		{ID: "www.example.com/CNAME", Name: "www.example.com", Type: constants.RecordTypeCNAME, Content: "example.com", TTL: 300},
+		{ID: "example.com/MX", Name: "example.com", Type: constants.RecordTypeMX, Content: "mx.example.com", TTL: 300, Priority: 10},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("List() = %+v, want %+v", records, want)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "hand-written comment") {
+		t.Error("zone file still contains the comment, the rewrite is expected to drop it")
+	}
+	if serial := strconv.Itoa(int(nextSerial(2026010100, time.Now()))); !strings.Contains(string(content), " "+serial+" ") {
+		t.Errorf("SOA serial was not incremented:\n%s", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("zone file mode = %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+	if _, err := os.Stat(reloaded); err != nil {
+		t.Errorf("reload command was not run: %v", err)
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		serial, want uint32
+	}{
+		{serial: 1, want: 2},
+		{serial: 2026010105, want: 2026101600},
+		{serial: 2026101600, want: 2026101601},
+		{serial: 2026101699, want: 2026101700},
+	}
+	for _, tt := range tests {
+		if got := nextSerial(tt.serial, now); got != tt.want {
+			t.Errorf("nextSerial(%d) = %d, want %d", tt.serial, got, tt.want)
+		}
+	}
+}
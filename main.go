@@ -51,14 +51,26 @@ func main() {
 	if dryRun {
 		slog.Info("Dry run enabled, changes won't be applied")
 	}
-	providers := map[string]dns.Provider{}
+	providers := map[string][]dns.Backend{}
+	var zoneSources []dns.ZoneSource
 	for _, zone := range appCfg.Zones {
-		dnsProvider, err := provider.Get(&zone, dryRun)
+		// Entries without name manage all zones of the account, they are discovered on every run
+		if zone.Name == "" {
+			zoneSource, err := provider.NewZoneSource(&zone, dryRun)
+			if err != nil {
+				slog.Error("Failed to create zone discovery", "provider", zone.Provider, "error", err)
+				os.Exit(1)
+			}
+			zoneSources = append(zoneSources, zoneSource)
+			continue
+		}
+
+		backends, err := provider.GetBackends(&zone, dryRun)
 		if err != nil {
 			slog.Error("Failed to create DNS provider", "zone", zone.Name, "error", err)
 			os.Exit(1)
 		}
-		providers[zone.Name] = dnsProvider
+		providers[zone.Name] = backends
 	}
 
 	dockerCli, err := client.New(client.FromEnv)
@@ -72,7 +84,7 @@ func main() {
 		}
 	}
 
-	dnsHandler := dns.NewHandler(providers, appCfg.DNS, appCfg.Domains, dockerCli)
+	dnsHandler := dns.NewHandler(providers, zoneSources, appCfg.DNS, appCfg.Domains, dockerCli)
 	//run function
 	run := func() {
 		if err := dnsHandler.Run(); err != nil {